@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+const (
+	defaultAPIPort = 17890
+
+	// sseKeepAlive 定期发送注释行，避免代理或客户端因空闲断开连接
+	sseKeepAlive = 15 * time.Second
+	// sseBuffer 每个订阅者缓冲的事件数，消费过慢时丢弃新事件而不阻塞下载
+	sseBuffer = 256
+)
+
+// APISettings 本地控制接口，仅监听 127.0.0.1，所有请求都需要携带 Token
+type APISettings struct {
+	Enabled bool   `json:"enabled"`
+	Port    int    `json:"port"`
+	Token   string `json:"token"` // 启用时为空则自动生成
+}
+
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// eventHub 将发往前端的事件同时分发给 SSE 订阅者
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan sseEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan sseEvent]struct{})}
+}
+
+func (h *eventHub) subscribe() (chan sseEvent, func()) {
+	ch := make(chan sseEvent, sseBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Emit 实现 backend.EventSink，事件名和内容与发给界面的一致
+func (h *eventHub) Emit(ev backend.Event) {
+	if coalesced(ev) {
+		return
+	}
+	h.publish(ev.Name(), ev.Payload())
+}
+
+func (h *eventHub) publish(name string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- sseEvent{name: name, data: payload}:
+		default:
+		}
+	}
+}
+
+// startAPI 按设置启动本地控制接口，未启用时不做任何事
+func (a *App) startAPI() error {
+	cfg := a.currentSettings().API
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("控制接口未设置 Token")
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("控制接口监听失败: %w", err)
+	}
+	server := &http.Server{
+		Handler:           a.apiHandler(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.apiServer = server
+	go server.Serve(listener)
+	return nil
+}
+
+func (a *App) stopAPI() {
+	if a.apiServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	a.apiServer.Shutdown(ctx)
+	a.apiServer = nil
+}
+
+// restartAPI 控制接口设置变化后重新监听，失败时记录到日志
+func (a *App) restartAPI() {
+	a.stopAPI()
+	if err := a.startAPI(); err != nil {
+		a.emitLog("error", err.Error())
+	}
+}
+
+// ensureAPIToken 启用控制接口但没有 Token 时生成一个并保存
+func (a *App) ensureAPIToken() {
+	current := a.currentSettings()
+	if !current.API.Enabled || current.API.Token != "" {
+		return
+	}
+	token, err := generateAPIToken()
+	if err != nil {
+		return
+	}
+	next := *current
+	next.API.Token = token
+	if a.settingsPath != "" {
+		saveSettingsFile(a.settingsPath, &next)
+	}
+	a.setSettings(&next)
+}
+
+func (a *App) apiHandler(cfg APISettings) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/tasks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetTasks())
+	})
+	mux.HandleFunc("POST /api/v1/script", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path  string `json:"path"`
+			Merge bool   `json:"merge"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			writeError(w, http.StatusBadRequest, errors.New("请求体需要包含脚本路径 path"))
+			return
+		}
+		load := a.LoadScript
+		if req.Merge {
+			load = a.LoadScriptMerge
+		}
+		info, err := load(req.Path)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		a.emit(backend.EventScriptLoaded, info)
+		writeJSON(w, http.StatusOK, info)
+	})
+	mux.HandleFunc("POST /api/v1/start", a.apiStart)
+	// 与界面一致，继续即重新启动所有未完成的文件，已下载部分会断点续传
+	mux.HandleFunc("POST /api/v1/resume", a.apiStart)
+	mux.HandleFunc("POST /api/v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		a.PauseAll()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/v1/progress", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetProgress())
+	})
+	// 返回的设置去掉了密码、Token 和签名密钥
+	mux.HandleFunc("GET /api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, redactSettings(a.currentSettings()))
+	})
+	// 只需提交要修改的字段，其余沿用当前设置；钩子命令只能在界面中修改，请求中的 hooks 会被忽略
+	mux.HandleFunc("PUT /api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &Settings{})
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("设置格式错误: %w", err))
+			return
+		}
+		// 合并到当前设置和保存在同一次修改中完成，不会覆盖界面同时提交的修改
+		err = a.patchSettings(func(next, current *Settings) error {
+			if err := json.Unmarshal(body, next); err != nil {
+				return err
+			}
+			next.Hooks = current.Hooks
+			restoreRedacted(next, current)
+			return nil
+		})
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, redactSettings(a.currentSettings()))
+	})
+	mux.HandleFunc("GET /api/v1/events", a.apiEvents)
+
+	return requireAPIToken(cfg, mux)
+}
+
+func (a *App) apiStart(w http.ResponseWriter, r *http.Request) {
+	started, err := a.StartAll()
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"started": started})
+}
+
+// apiEvents 以 Server-Sent Events 推送与界面相同的事件（progress、complete、error 等）
+func (a *App) apiEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("不支持流式响应"))
+		return
+	}
+	// 先订阅再返回响应头，客户端收到响应后发生的事件都不会遗漏
+	events, unsubscribe := a.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+		}
+		flusher.Flush()
+	}
+}
+
+// requireAPIToken 校验 Bearer Token（EventSource 无法设置请求头，也接受 ?token= 参数）
+// 同时只接受本机 Host 头，防止 DNS 重绑定页面借浏览器访问本地接口
+func requireAPIToken(cfg APISettings, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host != "127.0.0.1" && host != "localhost" && host != "::1" {
+			writeError(w, http.StatusForbidden, errors.New("只允许通过本机地址访问"))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("Token 无效"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
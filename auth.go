@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"isaac-downloader/backend"
+)
+
+// initAuth 根据当前平台地址创建认证管理器，并恢复已保存的登录状态
+func (a *App) initAuth() {
+	a.setAuth(nil)
+	platformURL := a.currentSettings().PlatformURL
+	if platformURL == "" {
+		return
+	}
+
+	dir, err := backend.AppConfigDir()
+	if err != nil {
+		a.emitLog("error", err.Error())
+		return
+	}
+	auth, err := backend.NewAuthManager(platformURL, backend.NewTokenStore(dir))
+	if err != nil {
+		a.emitLog("error", err.Error())
+		return
+	}
+	// 凭据无法读取时按未登录处理，用户仍可重新登录或注销以清除损坏的文件
+	if err := auth.Restore(); err != nil {
+		a.emitLog("warn", fmt.Sprintf("恢复登录状态失败，请重新登录: %v", err))
+	}
+	a.setAuth(auth)
+}
+
+func (a *App) requireAuth() (*backend.AuthManager, error) {
+	auth := a.currentAuth()
+	if auth == nil {
+		return nil, fmt.Errorf("未配置平台地址")
+	}
+	return auth, nil
+}
+
+// Login 使用用户名和密码登录平台
+func (a *App) Login(username, password string) (backend.AuthStatus, error) {
+	auth, err := a.requireAuth()
+	if err != nil {
+		return backend.AuthStatus{}, err
+	}
+	if err := auth.Login(a.ctx, username, password); err != nil {
+		return backend.AuthStatus{}, err
+	}
+	return auth.Status(), nil
+}
+
+// LoginWithToken 使用平台生成的 API Token 登录
+func (a *App) LoginWithToken(token string) (backend.AuthStatus, error) {
+	auth, err := a.requireAuth()
+	if err != nil {
+		return backend.AuthStatus{}, err
+	}
+	if err := auth.LoginWithAPIToken(token); err != nil {
+		return backend.AuthStatus{}, err
+	}
+	return auth.Status(), nil
+}
+
+// Logout 注销并删除本地保存的凭据
+func (a *App) Logout() error {
+	auth, err := a.requireAuth()
+	if err != nil {
+		return err
+	}
+	return auth.Logout(a.ctx)
+}
+
+func (a *App) GetAuthStatus() backend.AuthStatus {
+	auth := a.currentAuth()
+	if auth == nil {
+		return backend.AuthStatus{}
+	}
+	return auth.Status()
+}
@@ -0,0 +1,549 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authKeyFile = "auth.key"
+	authKeySize = 32
+
+	// 登录、刷新、注销请求的超时
+	authRequestTimeout = 30 * time.Second
+
+	// 令牌到期前提前刷新的余量
+	tokenRefreshSkew = time.Minute
+)
+
+var ErrNotLoggedIn = errors.New("未登录平台")
+
+// AuthToken 平台访问凭据
+type AuthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	IsAPIToken   bool      `json:"isApiToken,omitempty"`
+}
+
+func (t *AuthToken) expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt.Add(-tokenRefreshSkew))
+}
+
+// AuthStatus 返回给前端的登录状态，不包含任何令牌内容
+type AuthStatus struct {
+	LoggedIn   bool      `json:"loggedIn"`
+	Username   string    `json:"username"`
+	IsAPIToken bool      `json:"isApiToken"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// TokenStore 将令牌和其他敏感设置以 AES-GCM 加密保存在用户配置目录，每个平台主机一个令牌文件
+// 密钥单独保存在同目录的 auth.key 中，仅当前用户可读
+type TokenStore struct {
+	dir string
+}
+
+func NewTokenStore(dir string) *TokenStore {
+	return &TokenStore{dir: dir}
+}
+
+// tokenFile 返回 host 对应的凭据文件，切换平台后不会读到其他平台的令牌
+func (s *TokenStore) tokenFile(host string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(host))
+	return filepath.Join(s.dir, "auth-"+name+".dat")
+}
+
+func (s *TokenStore) Load(host string) (*AuthToken, error) {
+	plain, err := s.read(s.tokenFile(host))
+	if plain == nil || err != nil {
+		return nil, err
+	}
+	var token AuthToken
+	if err := json.Unmarshal(plain, &token); err != nil {
+		return nil, fmt.Errorf("凭据格式错误: %w", err)
+	}
+	return &token, nil
+}
+
+func (s *TokenStore) Save(host string, token *AuthToken) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return s.write(s.tokenFile(host), plain)
+}
+
+// Clear 删除已保存的凭据，密钥保留以便下次登录复用
+func (s *TokenStore) Clear(host string) error {
+	return s.remove(s.tokenFile(host))
+}
+
+// secretFile 返回其他敏感设置（如代理密码）的加密文件
+func (s *TokenStore) secretFile(name string) string {
+	return filepath.Join(s.dir, "secret-"+name+".dat")
+}
+
+// LoadSecret 读取加密保存的敏感设置，不存在时返回空字符串
+func (s *TokenStore) LoadSecret(name string) (string, error) {
+	plain, err := s.read(s.secretFile(name))
+	return string(plain), err
+}
+
+// SaveSecret 加密保存敏感设置，value 为空时删除
+func (s *TokenStore) SaveSecret(name, value string) error {
+	if value == "" {
+		return s.remove(s.secretFile(name))
+	}
+	return s.write(s.secretFile(name), []byte(value))
+}
+
+// read 读取并解密文件，文件不存在时返回 nil
+func (s *TokenStore) read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据失败: %w", err)
+	}
+
+	key, err := s.key(false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("凭据文件已损坏")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("解密凭据失败: %w", err)
+	}
+	return plain, nil
+}
+
+func (s *TokenStore) write(path string, plain []byte) error {
+	key, err := s.key(true)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data := gcm.Seal(nonce, nonce, plain, nil)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("保存凭据失败: %w", err)
+	}
+	return nil
+}
+
+func (s *TokenStore) remove(path string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除凭据失败: %w", err)
+	}
+	return nil
+}
+
+// key 读取密钥，create 为 true 且密钥不存在时生成新密钥
+// 已有密钥长度无效时返回错误而不是替换，否则所有已保存的凭据都将无法解密
+func (s *TokenStore) key(create bool) ([]byte, error) {
+	path := filepath.Join(s.dir, authKeyFile)
+	key, err := readKey(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+	if !create {
+		return nil, fmt.Errorf("凭据密钥缺失")
+	}
+
+	key = make([]byte, authKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	// 先写临时文件再硬链接到目标位置，目标已存在时链接失败，
+	// 保证并发首次保存（令牌和代理密码、多个进程）时只有一个密钥生效，且不会读到写了一半的密钥
+	tmp, err := os.CreateTemp(s.dir, authKeyFile+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("保存密钥失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(key)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存密钥失败: %w", err)
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return readKey(path)
+		}
+		return nil, fmt.Errorf("保存密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// readKey 读取并校验密钥文件，文件不存在时返回 os.ErrNotExist
+func readKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥失败: %w", err)
+	}
+	if len(key) != authKeySize {
+		return nil, fmt.Errorf("凭据密钥 %s 无效，已保存的凭据无法解密，请备份后删除该文件并重新登录", path)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// AuthManager 负责平台登录、令牌刷新与注销
+type AuthManager struct {
+	baseURL *url.URL
+	store   *TokenStore
+
+	mu     sync.Mutex
+	token  *AuthToken
+	client *http.Client
+
+	refreshMu sync.Mutex // 同一时间只发出一个刷新请求，避免轮换的 refresh token 被并发刷新作废
+}
+
+// NewAuthManager 创建认证管理器，初始为未登录状态，调用 Restore 恢复上次的登录状态
+func NewAuthManager(platformURL string, store *TokenStore) (*AuthManager, error) {
+	base, err := url.Parse(strings.TrimRight(platformURL, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("平台地址无效: %s", platformURL)
+	}
+
+	return &AuthManager{
+		baseURL: base,
+		client:  &http.Client{Timeout: authRequestTimeout},
+		store:   store,
+	}, nil
+}
+
+// SetTransport 登录、刷新和注销请求改用 rt，由下载引擎传入，与下载使用相同的代理和 TLS 设置
+func (m *AuthManager) SetTransport(rt http.RoundTripper) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.client = &http.Client{Timeout: authRequestTimeout, Transport: rt}
+}
+
+// Restore 从 store 读取当前平台的凭据；读取失败（文件损坏、密钥缺失）时保持未登录并返回错误
+func (m *AuthManager) Restore() error {
+	if m.store == nil {
+		return nil
+	}
+	token, err := m.store.Load(m.baseURL.Hostname())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.token = token
+	m.mu.Unlock()
+	return nil
+}
+
+type loginResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 秒
+}
+
+// Login 使用用户名和密码登录
+func (m *AuthManager) Login(ctx context.Context, username, password string) error {
+	if username == "" || password == "" {
+		return fmt.Errorf("用户名和密码不能为空")
+	}
+
+	resp, err := m.post(ctx, "/api/v1/auth/login", map[string]string{
+		"username": username,
+		"password": password,
+	}, "")
+	if err != nil {
+		return fmt.Errorf("登录失败: %w", err)
+	}
+
+	return m.setToken(&AuthToken{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    expiresAt(resp.ExpiresIn),
+		Username:     username,
+	})
+}
+
+// LoginWithAPIToken 使用平台生成的长期 API Token 登录，API Token 不需要刷新
+func (m *AuthManager) LoginWithAPIToken(token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return fmt.Errorf("API Token 不能为空")
+	}
+	return m.setToken(&AuthToken{AccessToken: token, IsAPIToken: true})
+}
+
+// Refresh 使用 refresh token 换取新的 access token，并发调用时依次执行
+func (m *AuthManager) Refresh(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	return m.refresh(ctx)
+}
+
+// refresh 调用方需持有 refreshMu
+func (m *AuthManager) refresh(ctx context.Context) error {
+	current := m.currentToken()
+	if current == nil {
+		return ErrNotLoggedIn
+	}
+	if current.IsAPIToken || current.RefreshToken == "" {
+		return fmt.Errorf("当前凭据不支持刷新")
+	}
+
+	resp, err := m.post(ctx, "/api/v1/auth/refresh", map[string]string{
+		"refreshToken": current.RefreshToken,
+	}, "")
+	if err != nil {
+		return fmt.Errorf("刷新令牌失败: %w", err)
+	}
+
+	refreshToken := resp.RefreshToken
+	if refreshToken == "" {
+		refreshToken = current.RefreshToken
+	}
+	return m.setToken(&AuthToken{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt(resp.ExpiresIn),
+		Username:     current.Username,
+	})
+}
+
+// Logout 通知平台注销（失败不影响本地清理），并删除本地凭据
+func (m *AuthManager) Logout(ctx context.Context) error {
+	m.mu.Lock()
+	current := m.token
+	m.token = nil
+	m.mu.Unlock()
+
+	if current != nil && !current.IsAPIToken {
+		m.post(ctx, "/api/v1/auth/logout", nil, current.AccessToken)
+	}
+	if m.store != nil {
+		return m.store.Clear(m.baseURL.Hostname())
+	}
+	return nil
+}
+
+func (m *AuthManager) Status() AuthStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == nil {
+		return AuthStatus{}
+	}
+	return AuthStatus{
+		LoggedIn:   true,
+		Username:   m.token.Username,
+		IsAPIToken: m.token.IsAPIToken,
+		ExpiresAt:  m.token.ExpiresAt,
+	}
+}
+
+// IsPlatformURL 判断请求目标是否为平台地址：协议、主机和端口都需一致，主机不区分大小写
+// 只比较主机会把令牌以明文发给同一主机上的 http 地址或其他端口
+func (m *AuthManager) IsPlatformURL(u *url.URL) bool {
+	return strings.EqualFold(u.Scheme, m.baseURL.Scheme) &&
+		strings.EqualFold(u.Hostname(), m.baseURL.Hostname()) &&
+		urlPort(u) == urlPort(m.baseURL)
+}
+
+// AccessToken 返回可用的 access token，过期时自动刷新；并发请求只触发一次刷新
+func (m *AuthManager) AccessToken(ctx context.Context) (string, error) {
+	current := m.currentToken()
+	if current == nil {
+		return "", ErrNotLoggedIn
+	}
+	if current.expired(time.Now()) {
+		m.refreshMu.Lock()
+		// 等待期间令牌可能已被其他请求刷新
+		var err error
+		if current = m.currentToken(); current != nil && current.expired(time.Now()) {
+			err = m.refresh(ctx)
+			current = m.currentToken()
+		}
+		m.refreshMu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		if current == nil {
+			return "", ErrNotLoggedIn
+		}
+	}
+	return current.AccessToken, nil
+}
+
+func (m *AuthManager) currentToken() *AuthToken {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token
+}
+
+func (m *AuthManager) setToken(token *AuthToken) error {
+	if token.AccessToken == "" {
+		return fmt.Errorf("平台未返回 access token")
+	}
+	m.mu.Lock()
+	m.token = token
+	m.mu.Unlock()
+
+	if m.store != nil {
+		return m.store.Save(m.baseURL.Hostname(), token)
+	}
+	return nil
+}
+
+func (m *AuthManager) post(ctx context.Context, path string, body any, bearer string) (*loginResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.baseURL.String()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	m.mu.Lock()
+	client := m.client
+	m.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("平台返回异常状态码: %d", resp.StatusCode)
+	}
+
+	var result loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("响应格式错误: %w", err)
+	}
+	return &result, nil
+}
+
+func expiresAt(expiresIn int64) time.Time {
+	if expiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(expiresIn) * time.Second)
+}
+
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// urlPort 返回地址的端口，未指定时按协议取默认端口
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
+
+// AuthTransport 只对平台主机的请求注入认证头，存储地址（预签名 URL）保持原样
+type AuthTransport struct {
+	Base http.RoundTripper
+	Auth *AuthManager
+}
+
+func NewAuthTransport(base http.RoundTripper, auth *AuthManager) *AuthTransport {
+	return &AuthTransport{Base: base, Auth: auth}
+}
+
+func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Auth == nil || !t.Auth.IsPlatformURL(req.URL) || req.Header.Get("Authorization") != "" {
+		return base.RoundTrip(req)
+	}
+
+	token, err := t.Auth.AccessToken(req.Context())
+	if err != nil {
+		// 未登录时按匿名请求发送，由平台决定是否拒绝
+		return base.RoundTrip(req)
+	}
+
+	// RoundTripper 不允许修改原请求
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token)
+	return base.RoundTrip(authReq)
+}
+
+// CloseIdleConnections 转发给底层 Transport，重建客户端时释放旧连接
+func (t *AuthTransport) CloseIdleConnections() {
+	if closer, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
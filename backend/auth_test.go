@@ -0,0 +1,276 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := NewTokenStore(dir)
+
+	token := &AuthToken{AccessToken: "secret-access", RefreshToken: "secret-refresh", Username: "alice"}
+	if err := store.Save("platform.example.com", token); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	// 落盘内容必须是密文
+	data, err := os.ReadFile(store.tokenFile("platform.example.com"))
+	if err != nil {
+		t.Fatalf("读取凭据文件失败: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-access")) {
+		t.Errorf("凭据文件包含明文令牌")
+	}
+
+	loaded, err := store.Load("PLATFORM.example.com")
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if loaded.AccessToken != "secret-access" || loaded.Username != "alice" {
+		t.Errorf("加载结果不一致: %+v", loaded)
+	}
+	if other, err := store.Load("other.example.com"); err != nil || other != nil {
+		t.Errorf("其他平台不应读到该令牌: %+v, %v", other, err)
+	}
+
+	if err := store.Clear("platform.example.com"); err != nil {
+		t.Fatalf("清除失败: %v", err)
+	}
+	if loaded, _ := store.Load("platform.example.com"); loaded != nil {
+		t.Errorf("清除后仍能加载凭据")
+	}
+}
+
+func TestAuthLoginAndRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/auth/login":
+			json.NewEncoder(w).Encode(loginResponse{AccessToken: "a1", RefreshToken: "r1", ExpiresIn: 1})
+		case "/api/v1/auth/refresh":
+			json.NewEncoder(w).Encode(loginResponse{AccessToken: "a2", ExpiresIn: 3600})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	auth, err := NewAuthManager(server.URL, NewTokenStore(t.TempDir()))
+	if err != nil {
+		t.Fatalf("创建认证管理器失败: %v", err)
+	}
+	if err := auth.Login(context.Background(), "alice", "pw"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	// expiresIn=1 秒，已落在刷新余量内，应自动刷新
+	token, err := auth.AccessToken(context.Background())
+	if err != nil {
+		t.Fatalf("获取令牌失败: %v", err)
+	}
+	if token != "a2" {
+		t.Errorf("期望刷新后的令牌 a2，实际: %s", token)
+	}
+	if status := auth.Status(); !status.LoggedIn || status.Username != "alice" || status.ExpiresAt.Before(time.Now()) {
+		t.Errorf("登录状态异常: %+v", status)
+	}
+
+	if err := auth.Logout(context.Background()); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	if auth.Status().LoggedIn {
+		t.Errorf("注销后仍为登录状态")
+	}
+}
+
+// 凭据文件损坏时 Restore 返回错误但保持未登录，注销可以清除损坏的文件
+func TestAuthRestoreCorruptToken(t *testing.T) {
+	store := NewTokenStore(t.TempDir())
+	if err := store.Save("platform.example.com", &AuthToken{AccessToken: "tok"}); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	path := store.tokenFile("platform.example.com")
+	os.WriteFile(path, []byte("corrupt-token-file-contents"), 0600)
+
+	auth, err := NewAuthManager("https://platform.example.com", store)
+	if err != nil {
+		t.Fatalf("创建认证管理器失败: %v", err)
+	}
+	if err := auth.Restore(); err == nil {
+		t.Errorf("凭据损坏时应返回错误")
+	}
+	if auth.Status().LoggedIn {
+		t.Errorf("凭据损坏时应为未登录状态")
+	}
+	if err := auth.Logout(context.Background()); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("注销后应删除损坏的凭据文件")
+	}
+}
+
+func TestAuthTransportOnlyPlatformHosts(t *testing.T) {
+	var gotAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer storage.Close()
+
+	auth, err := NewAuthManager("https://platform.example.com", nil)
+	if err != nil {
+		t.Fatalf("创建认证管理器失败: %v", err)
+	}
+	if err := auth.LoginWithAPIToken("tok"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	client := &http.Client{Transport: NewAuthTransport(http.DefaultTransport, auth)}
+	resp, err := client.Get(storage.URL + "/bucket/file.zip?X-Amz-Signature=abc")
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if gotAuth != "" {
+		t.Errorf("存储地址不应携带认证头，实际: %q", gotAuth)
+	}
+
+	cases := map[string]bool{
+		"https://PLATFORM.example.com/api":     true,
+		"https://platform.example.com:443/api": true,
+		"http://platform.example.com/api":      false, // 明文协议
+		"https://platform.example.com:8443/":   false, // 其他端口
+		"https://storage.example.com/a.zip":    false,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := auth.IsPlatformURL(u); got != want {
+			t.Errorf("IsPlatformURL(%s) = %v, 期望 %v", raw, got, want)
+		}
+	}
+}
+
+type closeRecorder struct {
+	http.RoundTripper
+	closed bool
+}
+
+func (r *closeRecorder) CloseIdleConnections() { r.closed = true }
+
+func TestAuthTransportClosesIdleConnections(t *testing.T) {
+	base := &closeRecorder{RoundTripper: http.DefaultTransport}
+	client := &http.Client{Transport: NewAuthTransport(base, nil)}
+	client.CloseIdleConnections()
+	if !base.closed {
+		t.Errorf("应转发 CloseIdleConnections 给底层 Transport")
+	}
+}
+
+// 密钥文件无效时返回错误，不能用新密钥替换，否则已保存的凭据全部无法解密
+func TestTokenStoreRejectsInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, authKeyFile)
+	os.WriteFile(keyPath, []byte("short"), 0600)
+
+	store := NewTokenStore(dir)
+	if err := store.Save("platform.example.com", &AuthToken{AccessToken: "tok"}); err == nil {
+		t.Errorf("密钥无效时保存应返回错误")
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != "short" {
+		t.Errorf("不应替换已有的密钥文件")
+	}
+}
+
+// 多个 TokenStore 同时首次保存时只生成一个密钥，所有内容都能解密
+func TestTokenStoreConcurrentFirstWrite(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := NewTokenStore(dir)
+			if err := store.SaveSecret(fmt.Sprintf("s%d", i), "value"); err != nil {
+				t.Errorf("保存失败: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	store := NewTokenStore(dir)
+	for i := 0; i < 8; i++ {
+		if got, err := store.LoadSecret(fmt.Sprintf("s%d", i)); err != nil || got != "value" {
+			t.Errorf("s%d 无法解密: %q, %v", i, got, err)
+		}
+	}
+}
+
+// 并发请求遇到过期令牌时只发出一次刷新请求
+func TestAuthRefreshSingleFlight(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/auth/refresh" {
+			refreshes.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			json.NewEncoder(w).Encode(loginResponse{AccessToken: "a2", RefreshToken: "r2", ExpiresIn: 3600})
+		}
+	}))
+	defer server.Close()
+
+	auth, err := NewAuthManager(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.token = &AuthToken{AccessToken: "a1", RefreshToken: "r1", ExpiresAt: time.Now().Add(-time.Hour)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := auth.AccessToken(context.Background()); err != nil || token != "a2" {
+				t.Errorf("获取令牌失败: %q, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("应只刷新一次，实际 %d 次", n)
+	}
+}
+
+// 登录请求使用引擎的代理设置
+func TestAuthLoginUsesEngineProxy(t *testing.T) {
+	var proxied atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(true)
+		json.NewEncoder(w).Encode(loginResponse{AccessToken: "a1"})
+	}))
+	defer proxy.Close()
+
+	auth, err := NewAuthManager("http://platform.invalid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultEngineOptions()
+	opts.Proxy = ProxyConfig{Mode: ProxyManual, URL: proxy.URL}
+	NewDownloadEngine(opts).SetAuth(auth)
+
+	if err := auth.Login(context.Background(), "alice", "pw"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if !proxied.Load() {
+		t.Errorf("登录请求应经过代理")
+	}
+}
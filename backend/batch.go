@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"sync"
+	"time"
+)
+
+// TaskSummary 一个采集任务（TaskInfo）下所有文件的完成情况
+type TaskSummary struct {
+	TaskId    int64  `json:"taskId"`
+	TaskName  string `json:"taskName"`
+	Files     int    `json:"files"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Succeeded 所有文件都下载成功
+func (s TaskSummary) Succeeded() bool {
+	return s.Failed == 0
+}
+
+func (s TaskSummary) done() bool {
+	return s.Completed+s.Failed >= s.Files
+}
+
+// BatchSummary 一次 StartAll 启动的整批下载的完成情况
+type BatchSummary struct {
+	Tasks     int           `json:"tasks"`
+	Files     int           `json:"files"`
+	Completed int           `json:"completed"`
+	Failed    int           `json:"failed"`
+	Bytes     int64         `json:"bytes"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// BatchTracker 统计一批下载中每个任务和整批的完成情况
+// 文件以 URL 标识，与 DownloadEngine 的任务键一致
+type BatchTracker struct {
+	mu       sync.Mutex
+	started  time.Time
+	taskOf   map[string]int64
+	finished map[string]bool
+	tasks    map[int64]*TaskSummary
+	batch    BatchSummary
+}
+
+func NewBatchTracker() *BatchTracker {
+	return &BatchTracker{
+		started:  time.Now(),
+		taskOf:   make(map[string]int64),
+		finished: make(map[string]bool),
+		tasks:    make(map[int64]*TaskSummary),
+	}
+}
+
+// Add 登记一个待下载文件
+func (b *BatchTracker) Add(taskId int64, taskName, url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.taskOf[url]; ok {
+		return
+	}
+	b.taskOf[url] = taskId
+	summary, ok := b.tasks[taskId]
+	if !ok {
+		summary = &TaskSummary{TaskId: taskId, TaskName: taskName}
+		b.tasks[taskId] = summary
+		b.batch.Tasks++
+	}
+	summary.Files++
+	b.batch.Files++
+}
+
+// Finish 记录文件结束（成功或失败）
+// 该文件使所属任务全部结束时返回任务汇总，使整批全部结束时返回整批汇总，否则对应返回 nil
+func (b *BatchTracker) Finish(task *DownloadTask, ok bool) (*TaskSummary, *BatchSummary) {
+	task.mu.Lock()
+	url, bytes := task.URL, task.DownloadedBytes
+	task.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	taskId, known := b.taskOf[url]
+	if !known || b.finished[url] {
+		return nil, nil
+	}
+	b.finished[url] = true
+
+	summary := b.tasks[taskId]
+	if ok {
+		summary.Completed++
+		summary.Bytes += bytes
+		b.batch.Completed++
+		b.batch.Bytes += bytes
+	} else {
+		summary.Failed++
+		b.batch.Failed++
+	}
+
+	var taskDone *TaskSummary
+	if summary.done() {
+		copied := *summary
+		taskDone = &copied
+	}
+
+	var batchDone *BatchSummary
+	if b.batch.Completed+b.batch.Failed >= b.batch.Files {
+		copied := b.batch
+		copied.Duration = time.Since(b.started)
+		batchDone = &copied
+	}
+	return taskDone, batchDone
+}
@@ -0,0 +1,347 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const catalogFileName = "catalog.json"
+
+// 抓取目录在索引中的状态
+const (
+	CapturePresent = "present"
+	CaptureMissing = "missing" // 上次索引的位置已不存在，且扫描时未在其他位置找到
+	CaptureMoved   = "moved"   // 在新的位置找到，MovedFrom 为原位置
+)
+
+type CatalogFile struct {
+	Path    string    `json:"path"` // 相对抓取目录，使用 / 分隔
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// CatalogEntry 一个抓取目录的索引
+type CatalogEntry struct {
+	TaskId     string        `json:"taskId"`
+	TaskName   string        `json:"taskName"`
+	CapturedAt time.Time     `json:"capturedAt"`
+	Dir        string        `json:"dir"`
+	Files      []CatalogFile `json:"files"`
+	FileCount  int           `json:"fileCount"`
+	TotalSize  int64         `json:"totalSize"`
+	Status     string        `json:"status"`
+	MovedFrom  string        `json:"movedFrom,omitempty"`
+	IndexedAt  time.Time     `json:"indexedAt"`
+}
+
+// key 同一任务 ID 和抓取时间视为同一次抓取，目录改名或移动后仍能对应上
+func (e *CatalogEntry) key() string {
+	return e.TaskId + "_" + e.CapturedAt.Format("20060102_150405")
+}
+
+// CatalogQuery 查询条件，字段为空表示不过滤
+type CatalogQuery struct {
+	Search string    `json:"search"` // 匹配任务名称或文件路径，不区分大小写
+	TaskId string    `json:"taskId"`
+	Status string    `json:"status"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+func (q CatalogQuery) match(e *CatalogEntry) bool {
+	if q.TaskId != "" && e.TaskId != q.TaskId {
+		return false
+	}
+	if q.Status != "" && e.Status != q.Status {
+		return false
+	}
+	if !q.Since.IsZero() && e.CapturedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.CapturedAt.After(q.Until) {
+		return false
+	}
+	if q.Search == "" {
+		return true
+	}
+	search := strings.ToLower(q.Search)
+	if strings.Contains(strings.ToLower(e.TaskName), search) {
+		return true
+	}
+	for _, f := range e.Files {
+		if strings.Contains(strings.ToLower(f.Path), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogScanResult 一次扫描的变化，元素为抓取目录路径
+type CatalogScanResult struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Moved   []string `json:"moved"`
+	Missing []string `json:"missing"`
+	// 与已索引的目录任务 ID 和抓取时间相同的其他目录（如复制的副本），不会加入索引
+	Duplicates []string `json:"duplicates"`
+	Total      int      `json:"total"`
+}
+
+// Catalog 已下载抓取目录的本地索引，保存在配置目录的 catalog.json 中
+// 可以索引多个下载目录，扫描其中一个时也会检查其他目录下的记录是否还存在
+type Catalog struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*CatalogEntry
+}
+
+// OpenCatalog 读取 dir 下的索引文件，dir 为空时使用用户配置目录；文件不存在时返回空索引
+func OpenCatalog(dir string) (*Catalog, error) {
+	if dir == "" {
+		var err error
+		if dir, err = AppConfigDir(); err != nil {
+			return nil, err
+		}
+	}
+	c := &Catalog{
+		path:    filepath.Join(dir, catalogFileName),
+		entries: make(map[string]*CatalogEntry),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取数据集索引失败: %w", err)
+	}
+	var entries []*CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("数据集索引格式错误: %w", err)
+	}
+	for _, e := range entries {
+		c.entries[e.key()] = e
+	}
+	return c, nil
+}
+
+// Scan 递归扫描 root 下的抓取目录并更新索引
+// 原位置消失的记录如果在 root 下的其他位置找到则标记为已移动，否则标记为缺失；
+// 同一次抓取出现在多个目录时保留已索引的位置，其余作为重复目录返回
+func (c *Catalog) Scan(root string) (*CatalogScanResult, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	found, err := scanCaptures(root)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := &CatalogScanResult{}
+	for key, candidates := range found {
+		old, exists := c.entries[key]
+		entry, duplicates := pickCapture(old, candidates)
+		result.Duplicates = append(result.Duplicates, duplicates...)
+		switch {
+		case entry == nil:
+			// 原位置仍在 root 之外，保留原记录
+			continue
+		case !exists:
+			result.Added = append(result.Added, entry.Dir)
+		case old.Dir != entry.Dir:
+			entry.Status = CaptureMoved
+			entry.MovedFrom = old.Dir
+			result.Moved = append(result.Moved, entry.Dir)
+		default:
+			result.Updated = append(result.Updated, entry.Dir)
+		}
+		c.entries[key] = entry
+	}
+	sort.Strings(result.Duplicates)
+	for key, entry := range c.entries {
+		if _, ok := found[key]; ok || dirExists(entry.Dir) {
+			continue
+		}
+		if entry.Status != CaptureMissing {
+			entry.Status = CaptureMissing
+			result.Missing = append(result.Missing, entry.Dir)
+		}
+	}
+	result.Total = len(c.entries)
+
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pickCapture 从同一次抓取的多个目录中选出要索引的一个，其余目录作为重复返回
+// 已索引的位置仍存在时优先保留它；该位置不在本次扫描范围内时返回 nil，不修改原记录
+func pickCapture(old *CatalogEntry, candidates []*CatalogEntry) (*CatalogEntry, []string) {
+	picked := 0
+	if old != nil && dirExists(old.Dir) {
+		picked = -1
+		for i, entry := range candidates {
+			if entry.Dir == old.Dir {
+				picked = i
+				break
+			}
+		}
+	}
+
+	var duplicates []string
+	for i, entry := range candidates {
+		if i != picked {
+			duplicates = append(duplicates, entry.Dir)
+		}
+	}
+	if picked < 0 {
+		return nil, duplicates
+	}
+	return candidates[picked], duplicates
+}
+
+// Query 返回符合条件的抓取目录，按抓取时间倒序
+func (c *Catalog) Query(q CatalogQuery) []CatalogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]CatalogEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		if q.match(e) {
+			result = append(result, *e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CapturedAt.Equal(result[j].CapturedAt) {
+			return result[i].CapturedAt.After(result[j].CapturedAt)
+		}
+		return result[i].Dir < result[j].Dir
+	})
+	return result
+}
+
+// RemoveMissing 删除缺失的记录，返回删除的数量
+func (c *Catalog) RemoveMissing() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, e := range c.entries {
+		if e.Status == CaptureMissing {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.save()
+}
+
+// save 先写临时文件再重命名，调用方需持有锁
+func (c *Catalog) save() error {
+	entries := make([]*CatalogEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key() < entries[j].key() })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("保存数据集索引失败: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存数据集索引失败: %w", err)
+	}
+	return nil
+}
+
+// scanCaptures 查找 root 下所有符合命名格式的抓取目录，按任务 ID 和抓取时间分组，组内按路径排序
+// 抓取目录内部不再查找嵌套的抓取目录
+func scanCaptures(root string) (map[string][]*CatalogEntry, error) {
+	if !dirExists(root) {
+		return nil, fmt.Errorf("目录不存在: %s", root)
+	}
+
+	found := make(map[string][]*CatalogEntry)
+	now := time.Now()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无权限等无法读取的子目录直接跳过
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		taskName, taskId, capturedAt, ok := ParseCaptureDirName(d.Name())
+		if !ok {
+			return nil
+		}
+		entry := &CatalogEntry{
+			TaskId:     taskId,
+			TaskName:   taskName,
+			CapturedAt: capturedAt,
+			Dir:        path,
+			Status:     CapturePresent,
+			IndexedAt:  now,
+		}
+		entry.Files = captureFiles(path)
+		entry.FileCount = len(entry.Files)
+		for _, f := range entry.Files {
+			entry.TotalSize += f.Size
+		}
+		found[entry.key()] = append(found[entry.key()], entry)
+		return fs.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("扫描下载目录失败: %w", err)
+	}
+	return found, nil
+}
+
+func captureFiles(dir string) []CatalogFile {
+	files := []CatalogFile{}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, CatalogFile{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	return files
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCaptureFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalogScanDetectsMovedAndMissing(t *testing.T) {
+	root := t.TempDir()
+	configDir := t.TempDir()
+	first := filepath.Join(root, "演示任务_1001_20260202_135655")
+	second := filepath.Join(root, "nested", "其他任务_1002_20260301_080000")
+	writeCaptureFile(t, filepath.Join(first, "a.zip"), 10)
+	writeCaptureFile(t, filepath.Join(first, "sub", "b.json"), 5)
+	writeCaptureFile(t, filepath.Join(second, "c.zip"), 7)
+	writeCaptureFile(t, filepath.Join(root, "notes", "readme.txt"), 1)
+
+	catalog, err := OpenCatalog(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := catalog.Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 2 || result.Total != 2 {
+		t.Fatalf("应索引 2 个抓取目录: %+v", result)
+	}
+
+	entries := catalog.Query(CatalogQuery{TaskId: "1001"})
+	if len(entries) != 1 || entries[0].TaskName != "演示任务" || entries[0].FileCount != 2 || entries[0].TotalSize != 15 {
+		t.Fatalf("索引内容不正确: %+v", entries)
+	}
+	if got := catalog.Query(CatalogQuery{Search: "C.ZIP"}); len(got) != 1 || got[0].TaskId != "1002" {
+		t.Errorf("按文件名搜索结果不正确: %+v", got)
+	}
+	if got := catalog.Query(CatalogQuery{}); len(got) != 2 || got[0].TaskId != "1002" {
+		t.Errorf("应按抓取时间倒序: %+v", got)
+	}
+
+	// 移动第一个目录并改名，删除第二个目录
+	moved := filepath.Join(root, "archive", "改名任务_1001_20260202_135655")
+	os.MkdirAll(filepath.Dir(moved), 0755)
+	if err := os.Rename(first, moved); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(second)
+
+	// 重新打开，确认索引已持久化
+	catalog, err = OpenCatalog(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = catalog.Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Moved) != 1 || result.Moved[0] != moved || len(result.Missing) != 1 || result.Missing[0] != second {
+		t.Fatalf("应检测到移动和缺失: %+v", result)
+	}
+	got := catalog.Query(CatalogQuery{TaskId: "1001"})
+	if len(got) != 1 || got[0].Status != CaptureMoved || got[0].MovedFrom != first || got[0].TaskName != "改名任务" {
+		t.Errorf("移动后的记录不正确: %+v", got)
+	}
+
+	if n, err := catalog.RemoveMissing(); err != nil || n != 1 {
+		t.Errorf("应删除 1 条缺失记录: %d, %v", n, err)
+	}
+	if got := catalog.Query(CatalogQuery{Status: CaptureMissing}); len(got) != 0 {
+		t.Errorf("缺失记录应已删除: %+v", got)
+	}
+}
+
+func TestCatalogScanReportsDuplicates(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	original := filepath.Join(root, "演示任务_1001_20260202_135655")
+	writeCaptureFile(t, filepath.Join(original, "a.zip"), 10)
+
+	catalog, err := OpenCatalog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Scan(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在同一下载目录下复制一份，原目录保留
+	copied := filepath.Join(root, "backup", "演示任务_1001_20260202_135655")
+	writeCaptureFile(t, filepath.Join(copied, "a.zip"), 10)
+	result, err := catalog.Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Duplicates) != 1 || result.Duplicates[0] != copied || len(result.Moved) != 0 {
+		t.Fatalf("副本应报告为重复: %+v", result)
+	}
+	if got := catalog.Query(CatalogQuery{}); len(got) != 1 || got[0].Dir != original || got[0].Status != CapturePresent {
+		t.Errorf("应保留原位置: %+v", got)
+	}
+
+	// 扫描另一个目录时发现副本，原目录仍存在，不应改写原记录
+	elsewhere := filepath.Join(other, "演示任务_1001_20260202_135655")
+	writeCaptureFile(t, filepath.Join(elsewhere, "a.zip"), 10)
+	result, err = catalog.Scan(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Duplicates) != 1 || result.Duplicates[0] != elsewhere || len(result.Updated) != 0 {
+		t.Fatalf("其他目录中的副本应报告为重复: %+v", result)
+	}
+	if got := catalog.Query(CatalogQuery{}); len(got) != 1 || got[0].Dir != original {
+		t.Errorf("原记录的位置不应被改写: %+v", got)
+	}
+}
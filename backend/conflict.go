@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EventConflict ask 策略下等待用户决定已有文件的处理方式，由应用层发出
+const EventConflict EventType = "conflict"
+
+// ConflictPolicy 开始下载时目标路径已有文件的处理策略
+type ConflictPolicy string
+
+const (
+	ConflictResume       ConflictPolicy = "resume"         // 以已有文件大小为起点续传（默认）
+	ConflictSkipSameSize ConflictPolicy = "skip-same-size" // 与远程大小相同时跳过，否则覆盖
+	ConflictOverwrite    ConflictPolicy = "overwrite"      // 删除已有文件后从头下载
+	ConflictRename       ConflictPolicy = "rename"         // 保留已有文件，另存为带序号的新文件
+	ConflictAsk          ConflictPolicy = "ask"            // 交给 ConflictResolver 逐个决定
+)
+
+func (p ConflictPolicy) Validate() error {
+	switch p {
+	case ConflictResume, ConflictSkipSameSize, ConflictOverwrite, ConflictRename, ConflictAsk:
+		return nil
+	default:
+		return fmt.Errorf("不支持的文件冲突策略: %s", p)
+	}
+}
+
+// ConflictDecision 记录在任务上的实际处理结果，为空表示尚未检查
+type ConflictDecision string
+
+const (
+	DecisionNone      ConflictDecision = "none" // 目标路径没有已有文件
+	DecisionResume    ConflictDecision = "resume"
+	DecisionSkip      ConflictDecision = "skip"
+	DecisionOverwrite ConflictDecision = "overwrite"
+	DecisionRename    ConflictDecision = "rename"
+)
+
+// ConflictInfo 交给 ConflictResolver 的冲突信息
+type ConflictInfo struct {
+	TaskId     string `json:"taskId"`
+	TaskName   string `json:"taskName"`
+	Path       string `json:"path"`
+	URL        string `json:"url"` // 已去除签名参数
+	LocalSize  int64  `json:"localSize"`
+	RemoteSize int64  `json:"remoteSize"` // 未知时为 -1
+}
+
+// ConflictResolver 在 ask 策略下决定单个文件的处理方式，返回 ask 或无效策略时按 resume 处理
+// 调用期间任务占用一个下载槽位，实现应在 ctx 取消时尽快返回
+type ConflictResolver func(ctx context.Context, info ConflictInfo) ConflictPolicy
+
+// SetConflictResolver 设置 ask 策略使用的回调，为 nil 时 ask 等同于 resume
+func (e *DownloadEngine) SetConflictResolver(resolver ConflictResolver) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolver = resolver
+}
+
+// resolveConflict 在任务首次开始时按策略处理目标路径上的已有文件，skip 为 true 时不需要下载
+// 决定记录在 task.Conflict 上，暂停后继续、卡死重新排队时不会再次处理
+func (e *DownloadEngine) resolveConflict(ctx context.Context, task *DownloadTask, opts EngineOptions) (skip bool, err error) {
+	task.mu.Lock()
+	decided := task.Conflict != ""
+	path := task.LocalPath
+	task.mu.Unlock()
+	if decided {
+		return false, nil
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil || info.IsDir() || info.Size() == 0 {
+		e.setConflict(task, DecisionNone, path)
+		return false, nil
+	}
+	localSize := info.Size()
+
+	policy := opts.ConflictPolicy
+	remoteSize := int64(-1)
+	if policy == ConflictSkipSameSize || policy == ConflictAsk {
+		// 探测失败时大小未知，skip-same-size 会改为续传，避免网络问题导致删除完整的本地文件
+		remoteSize, _, _ = e.remoteSize(ctx, task.URL, task, opts)
+	}
+	if policy == ConflictAsk {
+		e.mu.RLock()
+		resolver := e.resolver
+		e.mu.RUnlock()
+		policy = ConflictResume
+		if resolver != nil {
+			answer := resolver(ctx, ConflictInfo{
+				TaskId:     fmt.Sprint(task.TaskId),
+				TaskName:   task.TaskName,
+				Path:       path,
+				URL:        RedactURL(task.URL),
+				LocalSize:  localSize,
+				RemoteSize: remoteSize,
+			})
+			if answer.Validate() == nil && answer != ConflictAsk {
+				policy = answer
+			}
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+	}
+
+	decision := DecisionResume
+	switch policy {
+	case ConflictSkipSameSize:
+		switch {
+		case remoteSize == localSize:
+			decision = DecisionSkip
+		case remoteSize < 0:
+			decision = DecisionResume
+		default:
+			decision = DecisionOverwrite
+		}
+	case ConflictOverwrite:
+		decision = DecisionOverwrite
+	case ConflictRename:
+		decision = DecisionRename
+	}
+
+	switch decision {
+	case DecisionSkip:
+		task.mu.Lock()
+		task.DownloadedBytes = localSize
+		task.TotalBytes = localSize
+		task.mu.Unlock()
+	case DecisionOverwrite:
+		if err := os.Remove(path); err != nil {
+			return false, fmt.Errorf("删除已有文件失败: %w", err)
+		}
+	case DecisionRename:
+		renamed, err := availablePath(path)
+		if err != nil {
+			return false, err
+		}
+		path = renamed
+	}
+	e.setConflict(task, decision, path)
+	Logger().Info("目标文件已存在", "task_id", task.TaskId, "url", task.URL,
+		"policy", string(opts.ConflictPolicy), "decision", string(decision), "path", path, "local_size", localSize, "remote_size", remoteSize)
+	return decision == DecisionSkip, nil
+}
+
+// InheritConflict 沿用同一文件上一个任务的处理结果和目标路径
+// 暂停后重新开始会创建新任务，此时目标路径上是自己写入的部分文件，不应再按策略处理
+func (t *DownloadTask) InheritConflict(prev *DownloadTask) {
+	prev.mu.Lock()
+	decision, path := prev.Conflict, prev.LocalPath
+	prev.mu.Unlock()
+	if decision == "" {
+		return
+	}
+	t.mu.Lock()
+	t.Conflict = decision
+	t.LocalPath = path
+	t.mu.Unlock()
+}
+
+func (e *DownloadEngine) setConflict(task *DownloadTask, decision ConflictDecision, path string) {
+	task.mu.Lock()
+	task.Conflict = decision
+	task.LocalPath = path
+	task.mu.Unlock()
+}
+
+// availablePath 返回 path 或带 " (n)" 序号的第一个不存在的路径
+func availablePath(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; i < 1000; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("无法为 %s 找到可用的文件名", filepath.Base(path))
+}
@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// conflictServer 返回支持 Range 的固定内容，并统计 GET 请求次数
+func conflictServer(t *testing.T, gets *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+		http.ServeContent(w, r, "a.bin", time.Time{}, bytes.NewReader([]byte("hello world")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConflictPolicies(t *testing.T) {
+	cases := []struct {
+		policy   ConflictPolicy
+		existing string
+		decision ConflictDecision
+		target   string // 实际写入的文件名
+		kept     string // 原文件下载后的内容
+		gets     int32
+	}{
+		{ConflictResume, "hello", DecisionResume, "a.bin", "hello world", 1},
+		{ConflictOverwrite, "old", DecisionOverwrite, "a.bin", "hello world", 1},
+		{ConflictRename, "old", DecisionRename, "a (1).bin", "old", 1},
+		{ConflictSkipSameSize, "01234567890", DecisionSkip, "a.bin", "01234567890", 0},
+		{ConflictSkipSameSize, "old", DecisionOverwrite, "a.bin", "hello world", 1},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy)+"/"+tc.existing, func(t *testing.T) {
+			var gets atomic.Int32
+			server := conflictServer(t, &gets)
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.bin")
+			if err := os.WriteFile(path, []byte(tc.existing), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := DefaultEngineOptions()
+			opts.ConflictPolicy = tc.policy
+			task := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: path}
+			NewDownloadEngine(opts).StartDownload(task)
+
+			if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+				t.Fatalf("期望下载完成，实际: %s", status)
+			}
+			if task.Conflict != tc.decision {
+				t.Errorf("处理结果 = %s, 期望 %s", task.Conflict, tc.decision)
+			}
+			if want := filepath.Join(dir, tc.target); task.LocalPath != want {
+				t.Errorf("目标路径 = %s, 期望 %s", task.LocalPath, want)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tc.kept {
+				t.Errorf("原文件内容 = %q, 期望 %q", data, tc.kept)
+			}
+			if data, _ := os.ReadFile(task.LocalPath); tc.decision != DecisionSkip && string(data) != "hello world" {
+				t.Errorf("下载内容不正确: %q", data)
+			}
+			if got := gets.Load(); got != tc.gets {
+				t.Errorf("GET 请求 %d 次，期望 %d", got, tc.gets)
+			}
+		})
+	}
+}
+
+func TestConflictAskUsesResolver(t *testing.T) {
+	var gets atomic.Int32
+	server := conflictServer(t, &gets)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.bin")
+	os.WriteFile(path, []byte("old"), 0644)
+	os.WriteFile(filepath.Join(dir, "a (1).bin"), []byte("older"), 0644)
+
+	opts := DefaultEngineOptions()
+	opts.ConflictPolicy = ConflictAsk
+	engine := NewDownloadEngine(opts)
+	var asked ConflictInfo
+	engine.SetConflictResolver(func(ctx context.Context, info ConflictInfo) ConflictPolicy {
+		asked = info
+		return ConflictRename
+	})
+	task := &DownloadTask{TaskId: 7, TaskName: "cap", URL: server.URL + "/a.bin?sig=secret", LocalPath: path}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if asked.TaskId != "7" || asked.Path != path || asked.LocalSize != 3 || asked.RemoteSize != 11 {
+		t.Errorf("冲突信息不正确: %+v", asked)
+	}
+	if asked.URL != RedactURL(task.URL) {
+		t.Errorf("询问时应去除签名参数: %s", asked.URL)
+	}
+	if want := filepath.Join(dir, "a (2).bin"); task.Conflict != DecisionRename || task.LocalPath != want {
+		t.Errorf("应另存为 %s，实际 %s (%s)", want, task.LocalPath, task.Conflict)
+	}
+}
+
+func TestConflictAskWithoutResolverResumes(t *testing.T) {
+	var gets atomic.Int32
+	server := conflictServer(t, &gets)
+	path := filepath.Join(t.TempDir(), "a.bin")
+	os.WriteFile(path, []byte("hello"), 0644)
+
+	opts := DefaultEngineOptions()
+	opts.ConflictPolicy = ConflictAsk
+	engine := NewDownloadEngine(opts)
+	engine.SetConflictResolver(func(ctx context.Context, info ConflictInfo) ConflictPolicy {
+		return ConflictAsk // 无效回答按续传处理
+	})
+	task := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: path}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if data, _ := os.ReadFile(path); task.Conflict != DecisionResume || string(data) != "hello world" {
+		t.Errorf("应续传，实际 %s: %q", task.Conflict, data)
+	}
+}
+
+// TestSkipSameSizeUnknownRemoteResumes 探测不到远程大小时续传，不删除本地文件
+func TestSkipSameSizeUnknownRemoteResumes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "a.bin", time.Time{}, bytes.NewReader([]byte("hello world")))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "a.bin")
+	os.WriteFile(path, []byte("hello"), 0644)
+
+	opts := DefaultEngineOptions()
+	opts.ConflictPolicy = ConflictSkipSameSize
+	task := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: path}
+	NewDownloadEngine(opts).StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if data, _ := os.ReadFile(path); task.Conflict != DecisionResume || string(data) != "hello world" {
+		t.Errorf("远程大小未知时应续传，实际 %s: %q", task.Conflict, data)
+	}
+}
+
+func TestInheritConflict(t *testing.T) {
+	prev := &DownloadTask{LocalPath: "a (1).bin", Conflict: DecisionRename}
+	task := &DownloadTask{LocalPath: "a.bin"}
+	task.InheritConflict(prev)
+	if task.Conflict != DecisionRename || task.LocalPath != "a (1).bin" {
+		t.Errorf("应沿用上一个任务的处理结果: %+v", task)
+	}
+
+	task = &DownloadTask{LocalPath: "a.bin"}
+	task.InheritConflict(&DownloadTask{LocalPath: "other.bin"})
+	if task.Conflict != "" || task.LocalPath != "a.bin" {
+		t.Errorf("上一个任务未处理时不应修改: %+v", task)
+	}
+}
+
+func TestPlanActionPolicy(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "a.bin")
+	os.WriteFile(target, []byte("old"), 0644)
+
+	cases := map[ConflictPolicy]struct {
+		remote int64
+		want   PlanAction
+	}{
+		ConflictResume:       {11, PlanResume},
+		ConflictOverwrite:    {11, PlanOverwrite},
+		ConflictRename:       {11, PlanRename},
+		ConflictAsk:          {11, PlanAsk},
+		ConflictSkipSameSize: {3, PlanSkip},
+	}
+	for policy, tc := range cases {
+		item := &PlanItem{Target: target, Selected: true, LocalSize: 3, RemoteSize: tc.remote, Resumable: true}
+		if got, _ := planAction(item, PlanOptions{Policy: policy}); got != tc.want {
+			t.Errorf("%s: 处理方式 = %s, 期望 %s", policy, got, tc.want)
+		}
+	}
+
+	item := &PlanItem{Target: target, Selected: true, LocalSize: 3, RemoteSize: -1}
+	if got, _ := planAction(item, PlanOptions{Policy: ConflictSkipSameSize}); got != PlanResume {
+		t.Errorf("远程大小未知时应续传，实际 %s", got)
+	}
+}
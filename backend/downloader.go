@@ -2,11 +2,16 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,76 +22,194 @@ const (
 	StatusPending     DownloadStatus = "pending"
 	StatusDownloading DownloadStatus = "downloading"
 	StatusPaused      DownloadStatus = "paused"
+	StatusExtracting  DownloadStatus = "extracting"
 	StatusCompleted   DownloadStatus = "completed"
 	StatusFailed      DownloadStatus = "failed"
+	StatusCorrupt     DownloadStatus = "corrupt"
 )
 
+// maxCorruptRedownloads 校验失败后自动重新下载的次数上限
+const maxCorruptRedownloads = 1
+
 type DownloadTask struct {
 	URL             string
+	TaskId          int64 // 所属采集任务，供完成钩子等使用
+	TaskName        string
+	Script          string           // 来源脚本路径，写入下载历史
+	StartedAt       time.Time        // 首次开始下载的时间
+	Conflict        ConflictDecision // 开始时对目标路径已有文件的处理，为空表示尚未检查
 	LocalPath       string
+	Headers         map[string]string `json:"-"` // 每次请求附加的请求头，可能含凭据，不对外输出
+	Cookies         map[string]string `json:"-"` // 每次请求附加的 Cookie，可能含凭据，不对外输出
+	Mirrors         []string          // 备用镜像地址，URL 仍作为任务的唯一标识
 	TotalBytes      int64
 	DownloadedBytes int64
 	Status          DownloadStatus
 	Speed           int64
+	Stalls          int       // 因连接卡死被中止并重新排队的次数
+	Segments        []Segment // 每个镜像提供的字节区间
+	ExtractedBytes  int64     // 自动解压时已解压的字节数
+	ExtractTotal    int64     // 自动解压时压缩包内文件总字节数
+	CorruptReason   string    // 压缩包校验失败的原因
 	mu              sync.Mutex
 	cancel          context.CancelFunc
+	stallStreak     int      // 连续无任何进展的卡死次数
+	mirrors         []string // 按探测速度排序后的候选地址
+	activeMirror    int
+	redownloads     int // 因校验失败重新下载的次数
 }
 
 type DownloadEngine struct {
-	httpClient    *http.Client
-	maxConcurrent int
-	semaphore     chan struct{}
-	runningTasks  map[string]*DownloadTask
-	mu            sync.RWMutex
-	globalCtx     context.Context
-	globalCancel  context.CancelFunc
-	onProgress    func(*DownloadTask)
-	onComplete    func(*DownloadTask)
-	onError       func(*DownloadTask, error)
-}
-
-func NewDownloadEngine(maxConcurrent int) *DownloadEngine {
+	httpClient   *http.Client
+	opts         EngineOptions
+	auth         *AuthManager
+	semaphore    chan struct{}
+	runningTasks map[string]*DownloadTask
+	mu           sync.RWMutex
+	globalCtx    context.Context
+	globalCancel context.CancelFunc
+	events       *EventBus
+	metrics      *Metrics
+	callbacksOff func()           // 取消 SetCallbacks 注册的订阅
+	resolver     ConflictResolver // ask 策略下决定已有文件的处理方式
+}
+
+// errStalled 看门狗检测到连接长时间没有数据
+var errStalled = errors.New("连接卡死，长时间未收到数据")
+
+// httpStatusError 服务器返回了非预期的状态码
+type httpStatusError struct {
+	Code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("服务器返回异常状态码: %d", e.Code)
+}
+
+func NewDownloadEngine(opts EngineOptions) *DownloadEngine {
+	opts = opts.normalize()
 	ctx, cancel := context.WithCancel(context.Background())
-	return &DownloadEngine{
-		httpClient:    &http.Client{Timeout: 0},
-		maxConcurrent: maxConcurrent,
-		semaphore:     make(chan struct{}, maxConcurrent),
-		runningTasks:  make(map[string]*DownloadTask),
-		globalCtx:     ctx,
-		globalCancel:  cancel,
+	e := &DownloadEngine{
+		opts:         opts,
+		semaphore:    make(chan struct{}, opts.MaxConcurrent),
+		runningTasks: make(map[string]*DownloadTask),
+		globalCtx:    ctx,
+		globalCancel: cancel,
+		events:       NewEventBus(),
+		metrics:      NewMetrics(),
+	}
+	e.events.Subscribe(e.metrics)
+	e.rebuildClient()
+	return e
+}
+
+// ApplyOptions 运行中更新引擎参数
+// 新的超时、代理等连接参数对之后发起的请求生效；并发数变化只影响之后排队的任务
+func (e *DownloadEngine) ApplyOptions(opts EngineOptions) {
+	opts = opts.normalize()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if opts.MaxConcurrent != e.opts.MaxConcurrent {
+		e.semaphore = make(chan struct{}, opts.MaxConcurrent)
 	}
+	e.opts = opts
+	e.rebuildClient()
+}
+
+func (e *DownloadEngine) Options() EngineOptions {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.opts
+}
+
+// rebuildClient 按当前选项重建 http.Client，调用方需持有写锁或处于构造阶段
+func (e *DownloadEngine) rebuildClient() {
+	rt := newTransport(e.opts)
+	if e.auth != nil {
+		// 登录和刷新请求也要走用户配置的代理和 TLS，但不经过注入认证头的 AuthTransport
+		e.auth.SetTransport(rt)
+		rt = NewAuthTransport(rt, e.auth)
+	}
+
+	old := e.httpClient
+	e.httpClient = &http.Client{Transport: rt}
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+func (e *DownloadEngine) client() *http.Client {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.httpClient
+}
+
+// Events 返回引擎的事件总线，任务的排队、开始、进度、暂停、完成、失败都通过它发出
+func (e *DownloadEngine) Events() *EventBus {
+	return e.events
 }
 
+// SetCallbacks 以回调形式订阅进度、完成和失败事件，再次调用时替换之前的回调
 func (e *DownloadEngine) SetCallbacks(onProgress, onComplete func(*DownloadTask), onError func(*DownloadTask, error)) {
-	e.onProgress = onProgress
-	e.onComplete = onComplete
-	e.onError = onError
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.callbacksOff != nil {
+		e.callbacksOff()
+	}
+	e.callbacksOff = e.events.Subscribe(EventSinkFunc(func(ev Event) {
+		switch {
+		case ev.Type == EventProgress && onProgress != nil:
+			onProgress(ev.Task)
+		case ev.Type == EventCompleted && onComplete != nil:
+			onComplete(ev.Task)
+		case ev.Type == EventFailed && onError != nil:
+			onError(ev.Task, ev.Err)
+		}
+	}))
+}
+
+func (e *DownloadEngine) emit(typ EventType, task *DownloadTask, err error) {
+	e.events.Emit(Event{Type: typ, Task: task, Err: err})
+}
+
+// markPaused 标记任务已暂停并发出 paused 事件
+func (e *DownloadEngine) markPaused(task *DownloadTask) {
+	e.setStatus(task, StatusPaused)
+	e.emit(EventPaused, task, nil)
+}
+
+// SetAuth 为平台主机的请求注入认证头，传入 nil 时恢复匿名请求
+func (e *DownloadEngine) SetAuth(auth *AuthManager) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.auth = auth
+	e.rebuildClient()
 }
 
 func (e *DownloadEngine) StartDownload(task *DownloadTask) {
 	e.mu.Lock()
 	e.runningTasks[task.URL] = task
+	semaphore := e.semaphore
 	e.mu.Unlock()
+	e.emit(EventQueued, task, nil)
 
 	go func() {
 		// 等待 semaphore 时也检查全局 context，以便暂停能取消队列中的任务
 		select {
-		case e.semaphore <- struct{}{}:
+		case semaphore <- struct{}{}:
 			// 获得槽位
 		case <-e.globalCtx.Done():
-			task.mu.Lock()
-			task.Status = StatusPaused
-			task.mu.Unlock()
+			e.markPaused(task)
 			return
 		}
-		defer func() { <-e.semaphore }()
+		defer func() { <-semaphore }()
 
 		// 获得槽位后再次检查，防止在获取槽位的瞬间被取消
 		select {
 		case <-e.globalCtx.Done():
-			task.mu.Lock()
-			task.Status = StatusPaused
-			task.mu.Unlock()
+			e.markPaused(task)
 			return
 		default:
 		}
@@ -157,9 +280,20 @@ func (e *DownloadEngine) PauseAll() {
 }
 
 func (e *DownloadEngine) download(task *DownloadTask) {
+	e.mu.RLock()
 	ctx, cancel := context.WithCancel(e.globalCtx)
+	opts := e.opts
+	e.mu.RUnlock()
+	defer cancel()
+
+	task.mu.Lock()
 	task.cancel = cancel
 	task.Status = StatusDownloading
+	if task.StartedAt.IsZero() {
+		task.StartedAt = time.Now()
+	}
+	task.mu.Unlock()
+	e.emit(EventStarted, task, nil)
 
 	// 确保目录存在
 	if err := os.MkdirAll(filepath.Dir(task.LocalPath), 0755); err != nil {
@@ -167,6 +301,188 @@ func (e *DownloadEngine) download(task *DownloadTask) {
 		return
 	}
 
+	skip, err := e.resolveConflict(ctx, task, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			e.markPaused(task)
+			return
+		}
+		e.handleError(task, err)
+		return
+	}
+	if skip {
+		// 与续传时服务器返回 416 相同，直接进入校验和解压
+		if err := e.finish(ctx, task, opts); err != nil {
+			e.markPaused(task)
+		}
+		return
+	}
+
+	e.prepareMirrors(ctx, task, opts)
+
+	retries := 0
+	failovers := 0
+	for {
+		task.mu.Lock()
+		startBytes := task.DownloadedBytes
+		task.mu.Unlock()
+
+		attemptCtx := context.WithValue(ctx, attemptIDKey{}, newAttemptID())
+		log := attemptLogger(attemptCtx, task)
+		log.Info("开始下载", "mirror", task.currentURL(), "offset", startBytes, "retry", retries)
+		started := time.Now()
+
+		err := e.attempt(attemptCtx, task, opts)
+		if err == nil {
+			task.mu.Lock()
+			status, written := task.Status, task.DownloadedBytes-startBytes
+			task.mu.Unlock()
+			log.Info("下载结束", "status", status, "bytes", written, "duration_ms", time.Since(started).Milliseconds())
+			return
+		}
+		if ctx.Err() != nil {
+			// context 被取消（暂停），不是真正的下载错误
+			log.Info("下载已暂停")
+			e.markPaused(task)
+			return
+		}
+		if errors.Is(err, errStalled) {
+			log.Warn("连接卡死", "error", err)
+			e.metrics.stalls.Add(1)
+			// 卡死的镜像大概率仍不可用，重新排队前先换一个
+			task.nextMirror()
+			if e.requeueStalled(task, startBytes, opts) {
+				return
+			}
+			log.Error("多次卡死且没有进展，放弃下载")
+			e.handleError(task, err)
+			return
+		}
+
+		// 有其他镜像时立即切换，不消耗重试次数；所有镜像都失败后才按重试策略退避
+		if failovers < task.mirrorCount()-1 && canFailover(err) {
+			log.Warn("切换镜像", "error", err)
+			e.metrics.failovers.Add(1)
+			failovers++
+			task.nextMirror()
+			continue
+		}
+		if retries >= opts.MaxRetries || !isRetryable(err) {
+			log.Error("下载失败", "error", err, "retries", retries)
+			e.handleError(task, err)
+			return
+		}
+		failovers = 0
+
+		// 指数退避后从当前已写入的位置继续
+		delay := retryDelay(retries)
+		log.Warn("下载出错，稍后重试", "error", err, "delay_ms", delay.Milliseconds())
+		e.metrics.retries.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			e.markPaused(task)
+			return
+		}
+		retries++
+	}
+}
+
+// requeueStalled 释放当前槽位并把卡死的任务重新排队，下次从已写入的位置续传
+// 连续多次卡死且没有任何进展时返回 false，由调用方标记失败
+func (e *DownloadEngine) requeueStalled(task *DownloadTask, startBytes int64, opts EngineOptions) bool {
+	task.mu.Lock()
+	task.Stalls++
+	if task.DownloadedBytes > startBytes {
+		task.stallStreak = 0
+	} else {
+		task.stallStreak++
+	}
+	giveUp := task.stallStreak > opts.MaxRetries
+	if !giveUp {
+		task.Status = StatusPending
+		task.Speed = 0
+	}
+	task.mu.Unlock()
+
+	if giveUp {
+		return false
+	}
+	e.StartDownload(task)
+	return true
+}
+
+// watchStall 定期检查任务进度，超过 StallTimeout 没有新数据时以 errStalled 取消本次请求
+func (e *DownloadEngine) watchStall(ctx context.Context, cancel context.CancelCauseFunc, task *DownloadTask, timeout time.Duration) {
+	ticker := time.NewTicker(stallCheckInterval(timeout))
+	defer ticker.Stop()
+
+	task.mu.Lock()
+	lastBytes := task.DownloadedBytes
+	task.mu.Unlock()
+	lastProgress := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			task.mu.Lock()
+			current := task.DownloadedBytes
+			task.mu.Unlock()
+
+			if current != lastBytes {
+				lastBytes = current
+				lastProgress = now
+				continue
+			}
+			if now.Sub(lastProgress) >= timeout {
+				cancel(errStalled)
+				return
+			}
+		}
+	}
+}
+
+func stallCheckInterval(timeout time.Duration) time.Duration {
+	interval := timeout / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
+
+// attempt 执行一次 HTTP 请求并写入文件，成功完成时返回 nil
+func (e *DownloadEngine) attempt(parent context.Context, task *DownloadTask, opts EngineOptions) (err error) {
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	// 校验和解压不产生下载进度，进入 finish 前先停止看门狗并等待其退出，避免已完成的下载被判定为卡死
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		e.watchStall(watchCtx, cancel, task, opts.StallTimeout)
+	}()
+	finishing := false
+	finish := func() error {
+		stopWatch()
+		<-watchDone
+		finishing = true
+		return e.finish(parent, task, opts)
+	}
+	defer stopWatch()
+
+	// 看门狗取消的请求统一返回 errStalled，便于调用方区分暂停和卡死
+	defer func() {
+		if err != nil && !finishing && parent.Err() == nil && errors.Is(context.Cause(ctx), errStalled) {
+			err = errStalled
+		}
+	}()
+
 	// 检查已下载字节数（断点续传）
 	downloadedBytes := int64(0)
 	requestedRange := false
@@ -174,11 +490,13 @@ func (e *DownloadEngine) download(task *DownloadTask) {
 		downloadedBytes = info.Size()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", task.URL, nil)
+	mirror := task.currentURL()
+	req, err := http.NewRequestWithContext(ctx, "GET", mirror, nil)
 	if err != nil {
-		e.handleError(task, err)
-		return
+		return err
 	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	task.applyRequestHeaders(req)
 
 	// 设置 Range 头支持断点续传
 	if downloadedBytes > 0 {
@@ -186,40 +504,34 @@ func (e *DownloadEngine) download(task *DownloadTask) {
 		requestedRange = true
 	}
 
-	resp, err := e.httpClient.Do(req)
+	host := req.URL.Host
+	requestStart := time.Now()
+	resp, err := e.client().Do(req)
 	if err != nil {
-		// context 被取消不视为错误
-		if ctx.Err() == nil {
-			e.handleError(task, err)
-		}
-		return
+		e.metrics.observeRequest(host, 0, time.Since(requestStart))
+		return err
 	}
 	defer resp.Body.Close()
+	e.metrics.observeRequest(host, resp.StatusCode, time.Since(requestStart))
 
 	// 处理 416 Range Not Satisfiable：文件已完全下载
 	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
 		task.mu.Lock()
 		task.DownloadedBytes = downloadedBytes
 		task.TotalBytes = downloadedBytes
-		task.Status = StatusCompleted
 		task.mu.Unlock()
-		if e.onComplete != nil {
-			e.onComplete(task)
-		}
-		return
+		return finish()
 	}
 
 	// 确定文件打开模式和已下载字节数
 	openFlag := os.O_CREATE | os.O_WRONLY
+	task.mu.Lock()
 	if resp.StatusCode == http.StatusPartialContent {
 		// 服务器支持 Range，追加写入
 		openFlag |= os.O_APPEND
 		task.DownloadedBytes = downloadedBytes
 
-		// 解析 Content-Range: bytes start-end/total
-		var total int64
-		fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %*d-%*d/%d", &total)
-		if total > 0 {
+		if total := contentRangeTotal(resp.Header.Get("Content-Range")); total > 0 {
 			task.TotalBytes = total
 		}
 	} else if resp.StatusCode == http.StatusOK {
@@ -227,45 +539,56 @@ func (e *DownloadEngine) download(task *DownloadTask) {
 			// 发送了 Range 请求但服务器返回 200，说明不支持 Range
 			// 必须截断文件从头写入，否则会导致文件损坏
 			openFlag |= os.O_TRUNC
-			task.DownloadedBytes = 0
 		}
+		task.DownloadedBytes = 0
+		task.Segments = nil
 		if resp.ContentLength > 0 {
 			task.TotalBytes = resp.ContentLength
 		}
 	} else {
-		e.handleError(task, fmt.Errorf("服务器返回异常状态码: %d", resp.StatusCode))
-		return
+		task.mu.Unlock()
+		return &httpStatusError{Code: resp.StatusCode}
 	}
+	task.mu.Unlock()
 
 	file, err := os.OpenFile(task.LocalPath, openFlag, 0644)
 	if err != nil {
-		e.handleError(task, err)
-		return
+		return err
 	}
 	defer file.Close()
 
-	buf := make([]byte, 32*1024)
+	buf := make([]byte, opts.BufferSize)
 	lastUpdate := time.Now()
-	var lastBytes int64
+	task.mu.Lock()
+	lastBytes := task.DownloadedBytes
+	segmentStart := task.DownloadedBytes
+	task.mu.Unlock()
+
+	// 无论本次请求如何结束，都记录该镜像实际写入的区间
+	defer func() {
+		task.mu.Lock()
+		segmentEnd := task.DownloadedBytes
+		task.mu.Unlock()
+		task.recordSegment(mirror, segmentStart, segmentEnd)
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
-			task.Status = StatusPaused
-			return
+			return ctx.Err()
 		default:
 		}
 
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
 			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
-				e.handleError(task, writeErr)
-				return
+				return writeErr
 			}
 
 			task.mu.Lock()
 			task.DownloadedBytes += int64(n)
 			task.mu.Unlock()
+			e.metrics.addBytes(host, int64(n))
 
 			// 每秒更新进度
 			if time.Since(lastUpdate) > time.Second {
@@ -274,35 +597,143 @@ func (e *DownloadEngine) download(task *DownloadTask) {
 				lastBytes = task.DownloadedBytes
 				task.mu.Unlock()
 
-				if e.onProgress != nil {
-					e.onProgress(task)
-				}
+				e.emit(EventProgress, task, nil)
 				lastUpdate = time.Now()
 			}
 		}
 
 		if err != nil {
 			if err == io.EOF {
-				task.Status = StatusCompleted
-				if e.onComplete != nil {
-					e.onComplete(task)
-				}
-			} else if ctx.Err() != nil {
-				// context 被取消（暂停），不是真正的下载错误
-				task.Status = StatusPaused
-			} else {
-				e.handleError(task, err)
+				file.Close()
+				return finish()
+			}
+			return err
+		}
+	}
+}
+
+// finish 文件下载完成后执行后处理（校验、自动解压），全部成功后才标记完成
+// 后处理失败时直接标记任务状态并返回 nil，避免被当作网络错误重新下载
+func (e *DownloadEngine) finish(ctx context.Context, task *DownloadTask, opts EngineOptions) error {
+	if opts.ValidateZip && IsZipFile(task.LocalPath) {
+		err := ValidateZip(ctx, task.LocalPath)
+		var invalid *ZipValidationError
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			return err
+		case errors.As(err, &invalid):
+			attemptLogger(ctx, task).Warn("压缩包校验失败", "reason", invalid.Reason)
+			e.handleCorrupt(task, invalid, opts)
+			return nil
+		default:
+			attemptLogger(ctx, task).Error("压缩包校验出错", "error", err)
+			e.handleError(task, err)
+			return nil
+		}
+	}
+
+	if opts.AutoExtract && IsZipFile(task.LocalPath) {
+		if err := e.extract(ctx, task, opts); err != nil {
+			if ctx.Err() != nil {
+				return err
 			}
+			attemptLogger(ctx, task).Error("解压失败", "error", err)
+			e.handleError(task, fmt.Errorf("解压失败: %w", err))
+			return nil
+		}
+	}
+
+	e.setStatus(task, StatusCompleted)
+	e.emit(EventCompleted, task, nil)
+	return nil
+}
+
+// handleCorrupt 标记压缩包损坏；开启自动重新下载时删除文件并重新排队
+func (e *DownloadEngine) handleCorrupt(task *DownloadTask, invalid *ZipValidationError, opts EngineOptions) {
+	task.mu.Lock()
+	task.CorruptReason = invalid.Reason
+	redownload := opts.RedownloadCorrupt && task.redownloads < maxCorruptRedownloads
+	task.mu.Unlock()
+
+	if redownload {
+		if err := os.Remove(task.LocalPath); err == nil {
+			task.mu.Lock()
+			task.redownloads++
+			task.Status = StatusPending
+			task.DownloadedBytes = 0
+			task.Segments = nil
+			task.mu.Unlock()
+			e.StartDownload(task)
 			return
 		}
 	}
+
+	e.setStatus(task, StatusCorrupt)
+	e.emit(EventFailed, task, invalid)
 }
 
-func (e *DownloadEngine) handleError(task *DownloadTask, err error) {
-	task.Status = StatusFailed
-	if e.onError != nil {
-		e.onError(task, err)
+// extract 解压到同级目录，解压期间状态为 StatusExtracting 并通过 progress 事件上报进度
+// 解压仍占用下载槽位，从而限制同时进行的解压数量
+func (e *DownloadEngine) extract(ctx context.Context, task *DownloadTask, opts EngineOptions) error {
+	task.mu.Lock()
+	task.Status = StatusExtracting
+	task.Speed = 0
+	task.ExtractedBytes = 0
+	task.mu.Unlock()
+	e.emit(EventProgress, task, nil)
+
+	err := ExtractZip(ctx, task.LocalPath, ExtractDir(task.LocalPath), func(done, total int64) {
+		task.mu.Lock()
+		task.ExtractedBytes = done
+		task.ExtractTotal = total
+		task.mu.Unlock()
+		e.emit(EventProgress, task, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.DeleteArchiveAfterExtract {
+		if err := os.Remove(task.LocalPath); err != nil {
+			return fmt.Errorf("删除压缩包失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// isRetryable 本地文件错误和 4xx（除 408/429）不重试，网络错误和 5xx 重试
+func isRetryable(err error) bool {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return false
 	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 ||
+			statusErr.Code == http.StatusRequestTimeout ||
+			statusErr.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := time.Second << attempt
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
+	}
+	return delay
+}
+
+func (e *DownloadEngine) setStatus(task *DownloadTask, status DownloadStatus) {
+	task.mu.Lock()
+	task.Status = status
+	task.mu.Unlock()
+}
+
+func (e *DownloadEngine) handleError(task *DownloadTask, err error) {
+	e.setStatus(task, StatusFailed)
+	e.emit(EventFailed, task, err)
 }
 
 func (e *DownloadEngine) GetTask(url string) *DownloadTask {
@@ -322,10 +753,68 @@ func (e *DownloadEngine) GetRunningTasks() []*DownloadTask {
 	return tasks
 }
 
+// contentRangeTotal 解析 Content-Range 中的总大小，未知时返回 -1
+func contentRangeTotal(header string) int64 {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// applyRequestHeaders 为请求附加任务的自定义请求头和 Cookie
+// 首次请求、续传和重试都会调用，Range 由引擎在之后设置，不会被覆盖
+func (t *DownloadTask) applyRequestHeaders(req *http.Request) {
+	// 镜像地址可能属于第三方，自定义请求头（如 Authorization）只发送给主地址所在的主机
+	if primary, err := url.Parse(t.URL); err == nil && strings.EqualFold(primary.Host, req.URL.Host) {
+		for name, value := range t.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+	for _, cookie := range t.requestCookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+}
+
+// requestCookies 按 Cookie 作用域返回 u 应携带的 Cookie
+// 脚本中的 Cookie 视为主地址颁发的 host-only Cookie，镜像和其他主机不会收到
+func (t *DownloadTask) requestCookies(u *url.URL) []*http.Cookie {
+	if len(t.Cookies) == 0 {
+		return nil
+	}
+	primary, err := url.Parse(t.URL)
+	if err != nil {
+		return nil
+	}
+	jar, _ := cookiejar.New(nil)
+	cookies := make([]*http.Cookie, 0, len(t.Cookies))
+	for name, value := range t.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+	}
+	jar.SetCookies(primary, cookies)
+	return jar.Cookies(u)
+}
+
+func (t *DownloadTask) GetStatus() DownloadStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Status
+}
+
+// ToMap 返回用于前端事件的任务快照，不包含请求头和 Cookie
 func (t *DownloadTask) ToMap() map[string]any {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	mirror := t.URL
+	if len(t.mirrors) > 0 {
+		mirror = t.mirrors[t.activeMirror]
+	}
+
 	return map[string]any{
 		"url":             t.URL,
 		"localPath":       t.LocalPath,
@@ -333,5 +822,12 @@ func (t *DownloadTask) ToMap() map[string]any {
 		"downloadedBytes": t.DownloadedBytes,
 		"status":          string(t.Status),
 		"speed":           t.Speed,
+		"stalls":          t.Stalls,
+		"corruptReason":   t.CorruptReason,
+		"extractedBytes":  t.ExtractedBytes,
+		"extractTotal":    t.ExtractTotal,
+		"conflict":        string(t.Conflict),
+		"mirror":          RedactURL(mirror),
+		"segments":        append([]Segment(nil), t.Segments...),
 	}
 }
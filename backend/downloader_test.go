@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForStatus 轮询任务状态直到进入终态或超时
+func waitForStatus(t *testing.T, task *DownloadTask, timeout time.Duration) DownloadStatus {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		task.mu.Lock()
+		status := task.Status
+		task.mu.Unlock()
+		if status == StatusCompleted || status == StatusFailed || status == StatusCorrupt {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("等待任务结束超时")
+	return ""
+}
+
+func TestDownloadRetriesServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.UserAgent = "test-agent"
+	opts.MaxRetries = 1
+	engine := NewDownloadEngine(opts)
+
+	task := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: filepath.Join(t.TempDir(), "a.bin")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("期望请求 2 次，实际: %d", got)
+	}
+	if got := userAgent.Load(); got != "test-agent" {
+		t.Errorf("期望 User-Agent 为 test-agent，实际: %v", got)
+	}
+	if data, _ := os.ReadFile(task.LocalPath); string(data) != "hello" {
+		t.Errorf("文件内容不正确: %q", data)
+	}
+}
+
+func TestDownloadDoesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.MaxRetries = 3
+	engine := NewDownloadEngine(opts)
+
+	task := &DownloadTask{URL: server.URL + "/b.bin", LocalPath: filepath.Join(t.TempDir(), "b.bin")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusFailed {
+		t.Fatalf("期望下载失败，实际: %s", status)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("403 不应重试，实际请求 %d 次", got)
+	}
+}
+
+func TestStalledDownloadIsRequeued(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// 首次请求写出部分数据后挂起，模拟卡死的连接
+			w.Header().Set("Content-Length", "10")
+			w.Write([]byte("hello"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if r.Header.Get("Range") != "bytes=5-" {
+			t.Errorf("期望从第 5 字节续传，实际 Range: %q", r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", "bytes 5-9/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("world"))
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.StallTimeout = 200 * time.Millisecond
+	engine := NewDownloadEngine(opts)
+
+	task := &DownloadTask{URL: server.URL + "/c.bin", LocalPath: filepath.Join(t.TempDir(), "c.bin")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if task.Stalls != 1 {
+		t.Errorf("期望记录 1 次卡死，实际: %d", task.Stalls)
+	}
+	if data, _ := os.ReadFile(task.LocalPath); string(data) != "helloworld" {
+		t.Errorf("文件内容不正确: %q", data)
+	}
+}
+
+// TestSlowExtractionNotStalled 解压期间没有下载进度，不应被看门狗判定为卡死
+func TestSlowExtractionNotStalled(t *testing.T) {
+	data := buildZip(t, map[string]string{"meta.txt": "capture"})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.AutoExtract = true
+	opts.StallTimeout = 50 * time.Millisecond
+	engine := NewDownloadEngine(opts)
+	engine.SetCallbacks(func(task *DownloadTask) {
+		if task.GetStatus() == StatusExtracting {
+			time.Sleep(4 * opts.StallTimeout) // 模拟耗时的解压
+		}
+	}, nil, nil)
+
+	task := &DownloadTask{URL: server.URL + "/part.zip", LocalPath: filepath.Join(t.TempDir(), "part.zip")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if task.Stalls != 0 || requests.Load() != 1 {
+		t.Errorf("解压期间不应判定为卡死: stalls=%d, requests=%d", task.Stalls, requests.Load())
+	}
+}
+
+func TestHeadersAppliedOnEveryRequest(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("请求 %d 缺少 Authorization 头", requests.Load()+1)
+		}
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			t.Errorf("请求 %d 缺少 Cookie", requests.Load()+1)
+		}
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	task := &DownloadTask{
+		URL:       server.URL + "/d.bin",
+		LocalPath: filepath.Join(t.TempDir(), "d.bin"),
+		Headers:   map[string]string{"Authorization": "Bearer secret"},
+		Cookies:   map[string]string{"session": "s1"},
+	}
+	NewDownloadEngine(DefaultEngineOptions()).StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if snapshot := fmt.Sprint(task.ToMap()); strings.Contains(snapshot, "secret") || strings.Contains(snapshot, "s1") {
+		t.Errorf("任务快照泄露了请求头或 Cookie: %s", snapshot)
+	}
+}
@@ -0,0 +1,138 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventQueued    EventType = "queued"    // 任务已加入队列，等待下载槽位
+	EventStarted   EventType = "started"   // 获得槽位，开始下载
+	EventProgress  EventType = "progress"  // 下载或解压进度更新
+	EventPaused    EventType = "paused"    // 被暂停（包括仍在队列中时）
+	EventCompleted EventType = "completed" // 下载及后处理全部成功
+	EventFailed    EventType = "failed"    // 重试耗尽、校验失败等最终失败
+
+	// 以下为应用层事件，不与具体下载任务关联，内容放在 Data 中
+	EventScriptLoaded EventType = "scriptLoaded"
+	EventLog          EventType = "log"
+)
+
+// Event 引擎或应用发出的事件
+// 任务事件携带 Task（失败时还有 Err），应用层事件携带 Data
+type Event struct {
+	Type EventType
+	Time time.Time
+	Task *DownloadTask
+	Err  error
+	Data any
+}
+
+// Name 返回前端和 SSE 使用的事件名
+// completed 和 failed 沿用早期的 complete、error，其余与 Type 相同
+func (e Event) Name() string {
+	switch e.Type {
+	case EventCompleted:
+		return "complete"
+	case EventFailed:
+		return "error"
+	default:
+		return string(e.Type)
+	}
+}
+
+// Payload 返回发给前端的内容：任务事件为任务快照，失败时附带 error 字段
+func (e Event) Payload() any {
+	if e.Task == nil {
+		return e.Data
+	}
+	payload := e.Task.ToMap()
+	if e.Err != nil {
+		payload["error"] = e.Err.Error()
+	}
+	return payload
+}
+
+// EventSink 事件接收方，Emit 在引擎的下载协程中同步调用，实现不应阻塞
+type EventSink interface {
+	Emit(Event)
+}
+
+// EventSinkFunc 允许直接用函数作为 EventSink
+type EventSinkFunc func(Event)
+
+func (f EventSinkFunc) Emit(ev Event) { f(ev) }
+
+// EventBus 将事件分发给所有订阅者，本身也是 EventSink，可以串联
+type EventBus struct {
+	mu    sync.RWMutex
+	next  int
+	sinks map[int]EventSink
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{sinks: make(map[int]EventSink)}
+}
+
+// Subscribe 添加订阅者，返回取消订阅的函数
+func (b *EventBus) Subscribe(sink EventSink) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.sinks[id] = sink
+	return func() {
+		b.mu.Lock()
+		delete(b.sinks, id)
+		b.mu.Unlock()
+	}
+}
+
+func (b *EventBus) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.RLock()
+	sinks := make([]EventSink, 0, len(b.sinks))
+	for _, sink := range b.sinks {
+		sinks = append(sinks, sink)
+	}
+	b.mu.RUnlock()
+
+	for _, sink := range sinks {
+		sink.Emit(ev)
+	}
+}
+
+// TextSink 将任务状态变化逐行写入 w，供命令行输出或纯文本日志使用
+// 进度事件过于频繁，不输出
+type TextSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTextSink(w io.Writer) *TextSink {
+	return &TextSink{w: w}
+}
+
+func (s *TextSink) Emit(ev Event) {
+	if ev.Task == nil || ev.Type == EventProgress {
+		return
+	}
+	ev.Task.mu.Lock()
+	path := ev.Task.LocalPath
+	ev.Task.mu.Unlock()
+
+	line := fmt.Sprintf("%s %-9s %s", ev.Time.Format("15:04:05"), ev.Type, path)
+	if ev.Err != nil {
+		line += ": " + ev.Err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.w, line)
+}
@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink 记录收到的事件类型，供测试断言
+type recordingSink struct {
+	mu     sync.Mutex
+	types  []EventType
+	closed chan struct{}
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{closed: make(chan struct{})}
+}
+
+func (s *recordingSink) Emit(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.types) > 0 && s.types[len(s.types)-1] == ev.Type && ev.Type == EventProgress {
+		return
+	}
+	s.types = append(s.types, ev.Type)
+	if ev.Type == EventCompleted || ev.Type == EventFailed {
+		close(s.closed)
+	}
+}
+
+func (s *recordingSink) wait(t *testing.T) []EventType {
+	t.Helper()
+	select {
+	case <-s.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待任务结束事件超时")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]EventType(nil), s.types...)
+}
+
+func TestEngineEmitsTypedEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 1024))
+	}))
+	defer server.Close()
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	sink := newRecordingSink()
+	var text bytes.Buffer
+	textSink := NewTextSink(&text)
+	// 先写文本再记录，保证 wait 返回时文本已写完
+	engine.Events().Subscribe(EventSinkFunc(func(ev Event) {
+		textSink.Emit(ev)
+		sink.Emit(ev)
+	}))
+
+	task := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: filepath.Join(t.TempDir(), "a.bin")}
+	engine.StartDownload(task)
+
+	got := sink.wait(t)
+	if len(got) < 3 || got[0] != EventQueued || got[1] != EventStarted || got[len(got)-1] != EventCompleted {
+		t.Errorf("事件顺序不正确: %v", got)
+	}
+	if !strings.Contains(text.String(), "completed") || strings.Contains(text.String(), "progress") {
+		t.Errorf("文本输出不正确: %q", text.String())
+	}
+}
+
+func TestEngineEmitsFailedWithError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	var failed Event
+	done := make(chan struct{})
+	engine.Events().Subscribe(EventSinkFunc(func(ev Event) {
+		if ev.Type == EventFailed {
+			failed = ev
+			close(done)
+		}
+	}))
+
+	engine.StartDownload(&DownloadTask{URL: server.URL + "/missing.bin", LocalPath: filepath.Join(t.TempDir(), "missing.bin")})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待失败事件超时")
+	}
+
+	if failed.Name() != "error" {
+		t.Errorf("失败事件应沿用 error 事件名，实际: %s", failed.Name())
+	}
+	payload, _ := failed.Payload().(map[string]any)
+	if payload["error"] == nil || payload["url"] == nil {
+		t.Errorf("失败事件应包含地址和错误信息: %v", payload)
+	}
+}
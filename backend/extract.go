@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExtractDir 返回压缩包的解压目录：与压缩包同级、去掉 .zip 后缀的目录
+func ExtractDir(archive string) string {
+	return strings.TrimSuffix(archive, filepath.Ext(archive))
+}
+
+// IsZipFile 根据扩展名判断是否为 zip 压缩包
+func IsZipFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".zip")
+}
+
+// ExtractZip 将压缩包解压到 destDir，onProgress 按解压后的字节数报告进度
+// 条目路径逃逸出 destDir（zip slip）或为符号链接时直接报错，不写入任何该条目内容
+func ExtractZip(ctx context.Context, archive, destDir string, onProgress func(done, total int64)) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("打开压缩包失败: %w", err)
+	}
+	defer reader.Close()
+
+	destDir, err = filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range reader.File {
+		total += int64(f.UncompressedSize64)
+	}
+
+	var done int64
+	lastReport := time.Now()
+	report := func(n int64, force bool) {
+		done += n
+		if onProgress != nil && (force || time.Since(lastReport) > time.Second) {
+			onProgress(done, total)
+			lastReport = time.Now()
+		}
+	}
+
+	for _, f := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target, err := safeJoin(destDir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("压缩包包含符号链接，拒绝解压: %s", f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := extractFile(ctx, f, target, report); err != nil {
+			return err
+		}
+	}
+	report(0, true)
+	return nil
+}
+
+// safeJoin 拼接条目路径并确保结果仍位于 destDir 内
+func safeJoin(destDir, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("压缩包条目使用了绝对路径: %s", name)
+	}
+	target := filepath.Join(destDir, filepath.FromSlash(strings.ReplaceAll(name, `\`, "/")))
+	rel, err := filepath.Rel(destDir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("压缩包条目路径越界: %s", name)
+	}
+	return target, nil
+}
+
+func extractFile(ctx context.Context, f *zip.File, target string, report func(int64, bool)) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("读取压缩包条目失败: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, &extractReader{ctx: ctx, r: src, report: report}); err != nil {
+		return fmt.Errorf("解压 %s 失败: %w", f.Name, err)
+	}
+	return nil
+}
+
+// extractReader 在每次读取前检查 context，使大文件解压也能及时响应暂停，并上报进度
+type extractReader struct {
+	ctx    context.Context
+	r      io.Reader
+	report func(int64, bool)
+}
+
+func (c *extractReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	c.report(int64(n), false)
+	return n, err
+}
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildZip 按 name -> content 生成不压缩（Store）的 zip 内容，便于测试中直接篡改数据
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatalf("创建条目失败: %v", err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("生成 zip 失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractZipRejectsZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.zip")
+	os.WriteFile(archive, buildZip(t, map[string]string{"../../escape.txt": "x"}), 0644)
+
+	err := ExtractZip(context.Background(), archive, ExtractDir(archive), nil)
+	if err == nil {
+		t.Fatalf("越界路径应被拒绝")
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "..", "escape.txt")); statErr == nil {
+		t.Errorf("越界文件不应被写出")
+	}
+}
+
+func TestAutoExtractAfterDownload(t *testing.T) {
+	data := buildZip(t, map[string]string{"frames/0001.json": "{}", "meta.txt": "capture"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.AutoExtract = true
+	opts.DeleteArchiveAfterExtract = true
+	engine := NewDownloadEngine(opts)
+
+	var sawExtracting bool
+	engine.SetCallbacks(func(task *DownloadTask) {
+		if task.GetStatus() == StatusExtracting {
+			sawExtracting = true
+		}
+	}, nil, nil)
+
+	archive := filepath.Join(t.TempDir(), "capture_1_20260202_135655", "part.zip")
+	task := &DownloadTask{URL: server.URL + "/part.zip", LocalPath: archive}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望完成，实际: %s", status)
+	}
+	if !sawExtracting {
+		t.Errorf("解压期间应上报 extracting 状态")
+	}
+	if content, err := os.ReadFile(filepath.Join(ExtractDir(archive), "meta.txt")); err != nil || string(content) != "capture" {
+		t.Errorf("解压内容不正确: %q, %v", content, err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Errorf("解压成功后应删除压缩包")
+	}
+}
@@ -1,12 +1,18 @@
 package backend
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// appDirName is the directory name used under the OS user directories
+const appDirName = "isaac-downloader"
+
 // FileInfoExtended extends file information with encoding details
 type FileInfoExtended struct {
 	Name          string `json:"name"`          // 仅文件名，用于显示
@@ -61,6 +67,49 @@ func ScanDirectoryWithDetails(dir string, extensions []string) ([]FileInfoExtend
 	return results, nil
 }
 
+// AppConfigDir returns the per-user config directory of the app,
+// creating it if it does not exist yet
+func AppConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	dir := filepath.Join(base, appDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// HashFile returns the hex-encoded SHA-256 digest of a file
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FormatBytes formats a byte count with binary units, e.g. "1.5 MB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // contains checks if a string slice contains a specific item
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -0,0 +1,290 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const historyFileName = "history.jsonl"
+
+// HistoryRecord 一个文件的下载记录，TaskId 使用字符串以免前端丢失 int64 精度
+type HistoryRecord struct {
+	Time       time.Time `json:"time"` // 结束时间
+	TaskId     string    `json:"taskId"`
+	TaskName   string    `json:"taskName"`
+	Script     string    `json:"script"` // 来源脚本路径
+	Host       string    `json:"host"`   // 只记录主机，地址中的签名不落盘
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256,omitempty"`
+	DurationMs int64     `json:"durationMs"` // 从首次开始下载到结束，包含暂停的时间
+	AvgSpeed   int64     `json:"avgSpeed"`   // 字节/秒
+	Outcome    string    `json:"outcome"`    // completed、failed、corrupt
+	Error      string    `json:"error,omitempty"`
+}
+
+// NewHistoryRecord 根据结束的任务生成记录，err 为空表示成功
+func NewHistoryRecord(task *DownloadTask, hash string, err error) HistoryRecord {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	now := time.Now()
+	rec := HistoryRecord{
+		Time:     now,
+		TaskId:   strconv.FormatInt(task.TaskId, 10),
+		TaskName: task.TaskName,
+		Script:   task.Script,
+		Path:     task.LocalPath,
+		Size:     task.DownloadedBytes,
+		SHA256:   hash,
+		Outcome:  string(task.Status),
+	}
+	if u, parseErr := url.Parse(task.URL); parseErr == nil {
+		rec.Host = u.Host
+	}
+	if !task.StartedAt.IsZero() {
+		duration := now.Sub(task.StartedAt)
+		rec.DurationMs = duration.Milliseconds()
+		if duration > 0 {
+			rec.AvgSpeed = int64(float64(task.DownloadedBytes) / duration.Seconds())
+		}
+	}
+	if err != nil {
+		rec.Error = RedactText(err.Error())
+	}
+	return rec
+}
+
+// HistoryQuery 查询条件，字段为空表示不过滤
+type HistoryQuery struct {
+	TaskId  string    `json:"taskId"`
+	Outcome string    `json:"outcome"`
+	Search  string    `json:"search"` // 匹配任务名称或路径，不区分大小写
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Limit   int       `json:"limit"`
+}
+
+func (q HistoryQuery) match(rec HistoryRecord) bool {
+	if q.TaskId != "" && rec.TaskId != q.TaskId {
+		return false
+	}
+	if q.Outcome != "" && rec.Outcome != q.Outcome {
+		return false
+	}
+	if !q.Since.IsZero() && rec.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && rec.Time.After(q.Until) {
+		return false
+	}
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(rec.TaskName), search) && !strings.Contains(strings.ToLower(rec.Path), search) {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultHistoryLimit 历史至少保留的最近记录数，更早的记录在压缩时丢弃
+const DefaultHistoryLimit = 20000
+
+// HistoryStore 以 JSON 行追加写入的本地下载历史
+// 每条记录单独一行，程序异常退出最多损坏最后一行，读取时跳过无法解析的行
+// 记录在首次使用时读入内存，查询不再读取文件；行数超过 limit 的两倍时重写文件，只保留最近 limit 条
+type HistoryStore struct {
+	mu      sync.Mutex
+	path    string
+	limit   int
+	loaded  bool
+	records []HistoryRecord // 按写入顺序排列，与文件中可解析的行一致
+	lines   int             // 文件行数，包括无法解析的行
+	partial bool            // 文件以异常退出留下的半行结尾，下次写入前先换行
+}
+
+// OpenHistoryStore 打开 dir 下的历史文件，dir 为空时使用用户配置目录
+func OpenHistoryStore(dir string) (*HistoryStore, error) {
+	if dir == "" {
+		var err error
+		if dir, err = AppConfigDir(); err != nil {
+			return nil, err
+		}
+	}
+	return &HistoryStore{path: filepath.Join(dir, historyFileName), limit: DefaultHistoryLimit}, nil
+}
+
+func (s *HistoryStore) Append(rec HistoryRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开历史记录失败: %w", err)
+	}
+	defer f.Close()
+	if s.partial {
+		data = append([]byte{'\n'}, data...)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入历史记录失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入历史记录失败: %w", err)
+	}
+	s.records = append(s.records, rec)
+	s.lines++
+	s.partial = false
+	return s.compactIfNeeded()
+}
+
+// Query 返回符合条件的记录，最新的在前
+func (s *HistoryStore) Query(q HistoryQuery) ([]HistoryRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	result := []HistoryRecord{}
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if !q.match(s.records[i]) {
+			continue
+		}
+		result = append(result, s.records[i])
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// load 首次使用时读入全部记录，调用方需持有 mu
+func (s *HistoryStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取历史记录失败: %w", err)
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	lines, partial := 0, false
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lines++
+			partial = line[len(line)-1] != '\n'
+			var rec HistoryRecord
+			if json.Unmarshal(line, &rec) == nil {
+				records = append(records, rec)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取历史记录失败: %w", err)
+		}
+	}
+	s.records, s.lines, s.partial, s.loaded = records, lines, partial, true
+	return s.compactIfNeeded()
+}
+
+// compactIfNeeded 文件行数超过 limit 的两倍时只保留最近 limit 条并重写文件，调用方需持有 mu
+func (s *HistoryStore) compactIfNeeded() error {
+	if s.limit <= 0 || s.lines <= 2*s.limit {
+		return nil
+	}
+	if len(s.records) > s.limit {
+		s.records = append([]HistoryRecord(nil), s.records[len(s.records)-s.limit:]...)
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range s.records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("压缩历史记录失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("压缩历史记录失败: %w", err)
+	}
+	s.lines, s.partial = len(s.records), false
+	return nil
+}
+
+var historyCSVHeader = []string{
+	"time", "taskId", "taskName", "script", "host", "path", "size", "sha256",
+	"durationMs", "avgSpeed", "outcome", "error",
+}
+
+// WriteHistoryCSV 以 CSV 写出历史记录，首行为表头
+func WriteHistoryCSV(w io.Writer, records []HistoryRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		row := []string{
+			rec.Time.Format(time.RFC3339),
+			csvText(rec.TaskId),
+			csvText(rec.TaskName),
+			csvText(rec.Script),
+			csvText(rec.Host),
+			csvText(rec.Path),
+			strconv.FormatInt(rec.Size, 10),
+			csvText(rec.SHA256),
+			strconv.FormatInt(rec.DurationMs, 10),
+			strconv.FormatInt(rec.AvgSpeed, 10),
+			csvText(rec.Outcome),
+			csvText(rec.Error),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText 在以公式字符开头的文本前加单引号，避免表格软件把任务名、路径等当作公式执行
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHistoryStoreQuery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, err := store.Query(HistoryQuery{}); err != nil || len(records) != 0 {
+		t.Fatalf("历史文件不存在时应返回空列表: %v, %v", records, err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, rec := range []HistoryRecord{
+		{Time: base, TaskId: "1", TaskName: "演示任务", Path: "/d/a.zip", Outcome: "completed"},
+		{Time: base.Add(time.Hour), TaskId: "2", TaskName: "其他", Path: "/d/b.zip", Outcome: "failed", Error: "HTTP 404"},
+		{Time: base.Add(2 * time.Hour), TaskId: "1", TaskName: "演示任务", Path: "/d/C.zip", Outcome: "completed"},
+	} {
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("写入第 %d 条失败: %v", i, err)
+		}
+	}
+	// 异常退出留下的半行应被跳过
+	f, _ := os.OpenFile(filepath.Join(dir, historyFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"time":`)
+	f.Close()
+
+	all, err := store.Query(HistoryQuery{})
+	if err != nil || len(all) != 3 || all[0].Path != "/d/C.zip" {
+		t.Fatalf("应按时间倒序返回全部记录: %+v, %v", all, err)
+	}
+	cases := []struct {
+		name  string
+		query HistoryQuery
+		want  int
+	}{
+		{"按任务", HistoryQuery{TaskId: "1"}, 2},
+		{"按结果", HistoryQuery{Outcome: "failed"}, 1},
+		{"搜索不区分大小写", HistoryQuery{Search: "c.zip"}, 1},
+		{"时间范围", HistoryQuery{Since: base.Add(30 * time.Minute), Until: base.Add(90 * time.Minute)}, 1},
+		{"数量限制", HistoryQuery{Limit: 2}, 2},
+	}
+	for _, c := range cases {
+		got, err := store.Query(c.query)
+		if err != nil || len(got) != c.want {
+			t.Errorf("%s: 期望 %d 条，实际 %+v, %v", c.name, c.want, got, err)
+		}
+	}
+}
+
+func TestNewHistoryRecord(t *testing.T) {
+	task := &DownloadTask{
+		TaskId:          42,
+		TaskName:        "演示任务",
+		Script:          "/scripts/a.py",
+		URL:             "https://cdn.example.com/a.zip?X-Amz-Signature=secret",
+		LocalPath:       "/d/a.zip",
+		DownloadedBytes: 2000,
+		Status:          StatusFailed,
+		StartedAt:       time.Now().Add(-2 * time.Second),
+	}
+	rec := NewHistoryRecord(task, "", errors.New("请求 https://cdn.example.com/a.zip?X-Amz-Signature=secret 失败"))
+	if rec.TaskId != "42" || rec.Host != "cdn.example.com" || rec.Outcome != "failed" || rec.Script != "/scripts/a.py" {
+		t.Errorf("记录字段不正确: %+v", rec)
+	}
+	if rec.DurationMs < 2000 || rec.AvgSpeed <= 0 || rec.AvgSpeed > 1000 {
+		t.Errorf("耗时或平均速度不正确: %+v", rec)
+	}
+	if bytes.Contains([]byte(rec.Error), []byte("secret")) {
+		t.Errorf("错误信息应去除签名: %s", rec.Error)
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	records := []HistoryRecord{{
+		Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), TaskId: "1", TaskName: "名称,带逗号",
+		Size: 10, DurationMs: 5, AvgSpeed: 2000, Outcome: "completed",
+	}}
+	if err := WriteHistoryCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("CSV 应包含表头和一行数据: %v, %v", rows, err)
+	}
+	if rows[1][0] != "2026-01-01T00:00:00Z" || rows[1][2] != "名称,带逗号" || rows[1][6] != "10" || rows[1][10] != "completed" {
+		t.Errorf("CSV 内容不正确: %v", rows[1])
+	}
+
+	// 以公式字符开头的文本加单引号，表格软件不会当作公式执行
+	buf.Reset()
+	records[0].TaskName = "=HYPERLINK(\"http://evil\")"
+	records[0].Path = "@SUM(A1)"
+	records[0].Error = "-1+1"
+	if err := WriteHistoryCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ = csv.NewReader(&buf).ReadAll()
+	if rows[1][2] != "'=HYPERLINK(\"http://evil\")" || rows[1][5] != "'@SUM(A1)" || rows[1][11] != "'-1+1" || rows[1][6] != "10" {
+		t.Errorf("公式字符未转义: %v", rows[1])
+	}
+}
+
+// TestHistoryStoreCompacts 行数超过上限的两倍时重写文件，只保留最近的记录
+func TestHistoryStoreCompacts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, historyFileName)
+	// 异常退出留下的半行不应与下一条记录粘连
+	os.WriteFile(path, []byte(`{"taskId":"0"}`+"\n"+`{"time":`), 0600)
+
+	store, err := OpenHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.limit = 3
+	for i := 1; i <= 5; i++ {
+		if err := store.Append(HistoryRecord{TaskId: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := os.ReadFile(path); bytes.Count(data, []byte("\n")) != 3 {
+		t.Fatalf("超过上限两倍后应压缩为 3 行:\n%s", data)
+	}
+
+	reopened, _ := OpenHistoryStore(dir)
+	records, err := reopened.Query(HistoryQuery{})
+	if err != nil || len(records) != 3 || records[0].TaskId != "5" || records[2].TaskId != "3" {
+		t.Errorf("应保留最近 3 条记录: %+v, %v", records, err)
+	}
+}
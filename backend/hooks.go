@@ -0,0 +1,168 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultHookTimeout = 60 * time.Second
+
+	// 钩子输出只保留开头部分，避免脚本输出过多撑爆日志
+	maxHookOutput = 64 * 1024
+)
+
+const (
+	HookFileComplete  = "file_complete"
+	HookTaskComplete  = "task_complete"
+	HookBatchComplete = "batch_complete"
+)
+
+// HookConfig 下载完成后执行的命令，为空表示不执行
+// 命令通过系统 shell 执行（Windows 为 cmd /C，其余为 sh -c），参数通过 ISAAC_* 环境变量传入
+type HookConfig struct {
+	OnFileComplete  string `json:"onFileComplete"`
+	OnTaskComplete  string `json:"onTaskComplete"`
+	OnBatchComplete string `json:"onBatchComplete"`
+	TimeoutSec      int    `json:"timeoutSec"`
+}
+
+func (c HookConfig) Timeout() time.Duration {
+	if c.TimeoutSec <= 0 {
+		return DefaultHookTimeout
+	}
+	return time.Duration(c.TimeoutSec) * time.Second
+}
+
+// HookResult 钩子执行结果
+type HookResult struct {
+	Output   string        `json:"output"`
+	ExitCode int           `json:"exitCode"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RunHook 执行钩子命令，超时后强制结束进程
+// 命令以非零状态退出时同时返回结果和错误，便于记录输出
+func RunHook(ctx context.Context, command string, env map[string]string, timeout time.Duration) (*HookResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// 脚本派生的子进程可能继续占用输出管道，超时后不再等待
+	cmd.WaitDelay = time.Second
+
+	output := &limitedBuffer{limit: maxHookOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &HookResult{
+		Output:   output.String(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Duration: time.Since(start),
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("钩子执行超时（%s）", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return result, fmt.Errorf("钩子退出码 %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return result, fmt.Errorf("钩子执行失败: %w", err)
+	}
+	return result, nil
+}
+
+// FileHookEnv 单个文件完成时的环境变量
+func FileHookEnv(task *DownloadTask, hash string) map[string]string {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	return map[string]string{
+		"ISAAC_EVENT":     HookFileComplete,
+		"ISAAC_FILE_PATH": task.LocalPath,
+		"ISAAC_FILE_URL":  RedactURL(task.URL),
+		"ISAAC_FILE_SIZE": strconv.FormatInt(task.DownloadedBytes, 10),
+		"ISAAC_FILE_HASH": hash,
+		"ISAAC_TASK_ID":   strconv.FormatInt(task.TaskId, 10),
+		"ISAAC_TASK_NAME": task.TaskName,
+	}
+}
+
+// TaskHookEnv 一个采集任务的所有文件结束时的环境变量
+func TaskHookEnv(summary *TaskSummary, downloadDir string) map[string]string {
+	status := "completed"
+	if !summary.Succeeded() {
+		status = "failed"
+	}
+	return map[string]string{
+		"ISAAC_EVENT":        HookTaskComplete,
+		"ISAAC_TASK_ID":      strconv.FormatInt(summary.TaskId, 10),
+		"ISAAC_TASK_NAME":    summary.TaskName,
+		"ISAAC_TASK_STATUS":  status,
+		"ISAAC_TASK_FILES":   strconv.Itoa(summary.Files),
+		"ISAAC_TASK_FAILED":  strconv.Itoa(summary.Failed),
+		"ISAAC_TASK_SIZE":    strconv.FormatInt(summary.Bytes, 10),
+		"ISAAC_DOWNLOAD_DIR": downloadDir,
+	}
+}
+
+// BatchHookEnv 整批下载结束时的环境变量
+func BatchHookEnv(summary *BatchSummary, downloadDir string) map[string]string {
+	return map[string]string{
+		"ISAAC_EVENT":          HookBatchComplete,
+		"ISAAC_BATCH_TASKS":    strconv.Itoa(summary.Tasks),
+		"ISAAC_BATCH_FILES":    strconv.Itoa(summary.Files),
+		"ISAAC_BATCH_FAILED":   strconv.Itoa(summary.Failed),
+		"ISAAC_BATCH_SIZE":     strconv.FormatInt(summary.Bytes, 10),
+		"ISAAC_BATCH_DURATION": strconv.FormatInt(int64(summary.Duration.Seconds()), 10),
+		"ISAAC_DOWNLOAD_DIR":   downloadDir,
+	}
+}
+
+// limitedBuffer 超过上限后丢弃多余输出
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			b.buf.Write(p[:remain])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n...（输出过长已截断）"
+	}
+	return b.buf.String()
+}
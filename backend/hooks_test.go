@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHookPassesEnvAndCapturesOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试命令使用 sh 语法")
+	}
+	task := &DownloadTask{URL: "https://cdn.example.com/a.zip?sig=secret", LocalPath: "/data/a.zip", DownloadedBytes: 42, TaskId: 7, TaskName: "capture"}
+	env := FileHookEnv(task, "abc123")
+
+	result, err := RunHook(context.Background(), `echo "$ISAAC_TASK_ID $ISAAC_TASK_NAME $ISAAC_FILE_SIZE $ISAAC_FILE_HASH $ISAAC_FILE_URL"; echo oops >&2`, env, 5*time.Second)
+	if err != nil {
+		t.Fatalf("钩子执行失败: %v", err)
+	}
+	if !strings.Contains(result.Output, "7 capture 42 abc123 https://cdn.example.com/a.zip") {
+		t.Errorf("环境变量未正确传入: %q", result.Output)
+	}
+	if strings.Contains(result.Output, "secret") {
+		t.Errorf("地址中的签名不应传给钩子: %q", result.Output)
+	}
+	if !strings.Contains(result.Output, "oops") {
+		t.Errorf("应同时捕获标准错误输出: %q", result.Output)
+	}
+}
+
+func TestRunHookTimeoutAndExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试命令使用 sh 语法")
+	}
+	start := time.Now()
+	if _, err := RunHook(context.Background(), "sleep 10", nil, 200*time.Millisecond); err == nil {
+		t.Errorf("超时的钩子应返回错误")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("超时后应及时结束钩子")
+	}
+
+	result, err := RunHook(context.Background(), "echo partial; exit 3", nil, 5*time.Second)
+	if err == nil || result.ExitCode != 3 {
+		t.Fatalf("期望退出码 3，实际: %+v, %v", result, err)
+	}
+	if !strings.Contains(result.Output, "partial") {
+		t.Errorf("失败时也应保留输出: %q", result.Output)
+	}
+}
+
+func TestBatchTrackerReportsTaskAndBatchCompletion(t *testing.T) {
+	tracker := NewBatchTracker()
+	tracker.Add(1, "first", "u1")
+	tracker.Add(1, "first", "u2")
+	tracker.Add(2, "second", "u3")
+
+	taskDone, batchDone := tracker.Finish(&DownloadTask{URL: "u1", DownloadedBytes: 10}, true)
+	if taskDone != nil || batchDone != nil {
+		t.Fatalf("任务未全部结束时不应返回汇总")
+	}
+
+	taskDone, batchDone = tracker.Finish(&DownloadTask{URL: "u2"}, false)
+	if taskDone == nil || taskDone.TaskId != 1 || taskDone.Failed != 1 || taskDone.Succeeded() {
+		t.Fatalf("任务 1 应结束且标记失败: %+v", taskDone)
+	}
+	if batchDone != nil {
+		t.Fatalf("整批尚未结束")
+	}
+
+	// 重复上报同一文件不应重复计数
+	if taskDone, _ = tracker.Finish(&DownloadTask{URL: "u2"}, false); taskDone != nil {
+		t.Errorf("重复上报不应再次触发任务完成")
+	}
+
+	taskDone, batchDone = tracker.Finish(&DownloadTask{URL: "u3", DownloadedBytes: 5}, true)
+	if taskDone == nil || !taskDone.Succeeded() {
+		t.Fatalf("任务 2 应成功结束: %+v", taskDone)
+	}
+	if batchDone == nil || batchDone.Tasks != 2 || batchDone.Files != 3 || batchDone.Failed != 1 || batchDone.Bytes != 15 {
+		t.Fatalf("整批汇总不正确: %+v", batchDone)
+	}
+}
@@ -0,0 +1,177 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	logDirName  = "logs"
+	logFileName = "isaac-downloader.log"
+
+	DefaultLogMaxSize    = 10 << 20 // 单个日志文件上限
+	DefaultLogMaxBackups = 5        // 保留的历史文件数
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+}
+
+// Logger 返回全局日志记录器，未调用 SetLogger 时丢弃所有日志
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// NewJSONHandler 创建写入 JSON 行的日志 Handler
+// 所有字符串和错误属性中的 URL 都会去掉查询参数，避免预签名地址的签名落盘
+func NewJSONHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+}
+
+func NewJSONLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewJSONHandler(w, level))
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactText(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(RedactText(err.Error()))
+		}
+	}
+	return a
+}
+
+// AppLogDir 返回日志目录（用户配置目录下的 logs），不存在时创建
+func AppLogDir() (string, error) {
+	base, err := AppConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, logDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	return dir, nil
+}
+
+// AppLogPath 返回当前日志文件路径
+func AppLogPath() (string, error) {
+	dir, err := AppLogDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName), nil
+}
+
+// RotatingFile 按大小滚动的日志文件：超过上限时 x.log 依次改名为 x.log.1、x.log.2……
+// 超出保留数量的最旧文件被删除
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.maxBackups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// newAttemptID 为每次下载尝试生成短 ID，用于在日志中串联同一次请求的记录
+func newAttemptID() string {
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type attemptIDKey struct{}
+
+// attemptLogger 返回带有下载尝试 ID 和任务地址的日志记录器
+func attemptLogger(ctx context.Context, task *DownloadTask) *slog.Logger {
+	id, _ := ctx.Value(attemptIDKey{}).(string)
+	return Logger().With("attempt_id", id, "url", task.URL, "task_id", task.TaskId)
+}
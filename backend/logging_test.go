@@ -0,0 +1,112 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 供并发写入日志的测试缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestJSONLoggerRedactsSignedURLs(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewJSONLogger(&buf, nil)
+	signed := "https://cdn.example.com/a.zip?X-Amz-Signature=secret"
+	log.Error("下载失败", "url", signed, "error", errors.New(`Get "`+signed+`": EOF`))
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("日志中不应包含签名: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "https://cdn.example.com/a.zip") {
+		t.Errorf("日志中应保留去掉签名的地址: %s", buf.String())
+	}
+}
+
+func TestRotatingFileKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	file, err := OpenRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("打开失败: %v", err)
+	}
+	defer file.Close()
+
+	line := []byte(strings.Repeat("x", 60) + "\n")
+	for i := 0; i < 5; i++ {
+		file.Write(line)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("应存在 %s: %v", filepath.Base(name), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("超出保留数量的文件应被删除")
+	}
+}
+
+func TestDownloadAttemptsAreLoggedWithID(t *testing.T) {
+	var buf syncBuffer
+	SetLogger(NewJSONLogger(&buf, nil))
+	defer SetLogger(NewJSONLogger(io.Discard, nil))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	task := &DownloadTask{URL: server.URL + "/a.bin?token=secret", LocalPath: filepath.Join(t.TempDir(), "a.bin")}
+	engine.StartDownload(task)
+	waitForStatus(t, task, 5*time.Second)
+
+	// 状态先于“下载结束”日志更新，稍等日志写入
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(buf.String(), "下载结束") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("日志不是合法 JSON: %s", scanner.Text())
+		}
+		if id, _ := entry["attempt_id"].(string); id != "" {
+			ids[id] = true
+		}
+	}
+	if len(ids) != 1 {
+		t.Errorf("一次成功的下载应只有一个尝试 ID，实际: %v", ids)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("日志中不应包含查询参数: %s", buf.String())
+	}
+}
@@ -0,0 +1,234 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultLogRingSize = 2000
+
+// LogEntry 内存中保留的一条日志，TaskId 使用字符串以免前端丢失 int64 精度
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	TaskId  string            `json:"taskId,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// LogFilter 查询条件，字段为空表示不过滤
+type LogFilter struct {
+	TaskId   string
+	MinLevel string // debug、info、warn、error
+	Limit    int    // 只返回最新的 Limit 条
+}
+
+// LogRing 固定容量的环形日志缓冲，写满后覆盖最旧的记录
+type LogRing struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func NewLogRing(capacity int) *LogRing {
+	if capacity <= 0 {
+		capacity = DefaultLogRingSize
+	}
+	return &LogRing{entries: make([]LogEntry, capacity)}
+}
+
+func (r *LogRing) Add(entry LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries 按时间顺序返回符合条件的记录
+func (r *LogRing) Entries(filter LogFilter) []LogEntry {
+	r.mu.Lock()
+	var ordered []LogEntry
+	if r.full {
+		ordered = append(ordered, r.entries[r.next:]...)
+	}
+	ordered = append(ordered, r.entries[:r.next]...)
+	r.mu.Unlock()
+
+	minLevel := parseLevel(filter.MinLevel)
+	result := make([]LogEntry, 0, len(ordered))
+	for _, entry := range ordered {
+		if filter.TaskId != "" && entry.TaskId != filter.TaskId {
+			continue
+		}
+		if parseLevel(entry.Level) < minLevel {
+			continue
+		}
+		result = append(result, entry)
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelDebug
+	}
+	return l
+}
+
+// WriteLogText 以纯文本逐行写出日志，用于导出
+func WriteLogText(w io.Writer, entries []LogEntry) error {
+	for _, entry := range entries {
+		line := fmt.Sprintf("%s [%s] %s", entry.Time.Format("2006-01-02 15:04:05.000"), entry.Level, entry.Message)
+		if entry.TaskId != "" {
+			line += " task_id=" + entry.TaskId
+		}
+		if entry.URL != "" {
+			line += " url=" + entry.URL
+		}
+		keys := make([]string, 0, len(entry.Attrs))
+		for k := range entry.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%q", k, entry.Attrs[k])
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logRingHandler 将日志写入 LogRing 的 slog.Handler，字符串同样去掉 URL 查询参数
+type logRingHandler struct {
+	ring   *LogRing
+	level  slog.Leveler
+	attrs  []groupedAttr
+	prefix string // 当前分组前缀，只作用于之后添加的属性
+}
+
+// groupedAttr 记录 WithAttrs 添加属性时所在的分组
+type groupedAttr struct {
+	prefix string
+	attr   slog.Attr
+}
+
+func NewLogRingHandler(ring *LogRing, level slog.Leveler) slog.Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &logRingHandler{ring: ring, level: level}
+}
+
+func (h *logRingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *logRingHandler) Handle(_ context.Context, record slog.Record) error {
+	entry := LogEntry{
+		Time:    record.Time,
+		Level:   strings.ToLower(record.Level.String()),
+		Message: RedactText(record.Message),
+	}
+	add := func(prefix string, a slog.Attr) {
+		value := redactAttr(nil, a).Value.String()
+		switch a.Key {
+		case "task_id":
+			if value != "0" {
+				entry.TaskId = value
+			}
+		case "url":
+			entry.URL = value
+		default:
+			if entry.Attrs == nil {
+				entry.Attrs = make(map[string]string)
+			}
+			entry.Attrs[prefix+a.Key] = value
+		}
+	}
+	for _, a := range h.attrs {
+		add(a.prefix, a.attr)
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		add(h.prefix, a)
+		return true
+	})
+	h.ring.Add(entry)
+	return nil
+}
+
+func (h *logRingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append([]groupedAttr(nil), h.attrs...)
+	for _, a := range attrs {
+		next.attrs = append(next.attrs, groupedAttr{prefix: h.prefix, attr: a})
+	}
+	return &next
+}
+
+func (h *logRingHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.prefix = h.prefix + name + "."
+	return &next
+}
+
+// teeHandler 将日志同时交给多个 Handler
+type teeHandler []slog.Handler
+
+func NewTeeHandler(handlers ...slog.Handler) slog.Handler {
+	return teeHandler(handlers)
+}
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, h := range t {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogRingOverwritesOldestAndFilters(t *testing.T) {
+	ring := NewLogRing(3)
+	log := slog.New(NewLogRingHandler(ring, slog.LevelDebug))
+
+	log.Info("first", "task_id", int64(1))
+	log.Warn("second", "task_id", int64(2))
+	log.Error("third", "task_id", int64(1), "url", "https://cdn.example.com/a.zip?sig=secret")
+	log.Info("fourth")
+
+	all := ring.Entries(LogFilter{})
+	if len(all) != 3 || all[0].Message != "second" || all[2].Message != "fourth" {
+		t.Fatalf("环形缓冲应只保留最新 3 条，实际: %+v", all)
+	}
+
+	byTask := ring.Entries(LogFilter{TaskId: "1"})
+	if len(byTask) != 1 || byTask[0].Message != "third" || byTask[0].URL != "https://cdn.example.com/a.zip" {
+		t.Errorf("按任务过滤结果不正确: %+v", byTask)
+	}
+
+	warnings := ring.Entries(LogFilter{MinLevel: "warn"})
+	if len(warnings) != 2 {
+		t.Errorf("按级别过滤应返回 2 条，实际: %+v", warnings)
+	}
+
+	if latest := ring.Entries(LogFilter{Limit: 1}); len(latest) != 1 || latest[0].Message != "fourth" {
+		t.Errorf("Limit 应返回最新的记录: %+v", latest)
+	}
+}
+
+func TestTeeHandlerWritesToAllHandlers(t *testing.T) {
+	ring := NewLogRing(10)
+	var buf bytes.Buffer
+	log := slog.New(NewTeeHandler(NewLogRingHandler(ring, slog.LevelWarn), NewJSONHandler(&buf, slog.LevelInfo)))
+
+	log.With("attempt_id", "abc").Info("only json")
+	log.With("attempt_id", "abc").Warn("both")
+
+	entries := ring.Entries(LogFilter{})
+	if len(entries) != 1 || entries[0].Attrs["attempt_id"] != "abc" {
+		t.Errorf("环形缓冲应只收到 warn 及以上且保留预设属性: %+v", entries)
+	}
+	if !strings.Contains(buf.String(), "only json") || !strings.Contains(buf.String(), "both") {
+		t.Errorf("JSON 输出应包含两条记录: %s", buf.String())
+	}
+
+	var text bytes.Buffer
+	WriteLogText(&text, entries)
+	if !strings.Contains(text.String(), "[warn] both") || !strings.Contains(text.String(), `attempt_id="abc"`) {
+		t.Errorf("导出文本格式不正确: %s", text.String())
+	}
+}
+
+// 分组前缀只作用于 WithGroup 之后添加的属性
+func TestLogRingHandlerGroupPrefix(t *testing.T) {
+	ring := NewLogRing(10)
+	log := slog.New(NewLogRingHandler(ring, slog.LevelInfo))
+
+	log.With("before", "1").WithGroup("g").With("inside", "2").WithGroup("h").Info("msg", "leaf", "3")
+
+	entries := ring.Entries(LogFilter{})
+	if len(entries) != 1 {
+		t.Fatalf("期望 1 条记录，实际: %+v", entries)
+	}
+	want := map[string]string{"before": "1", "g.inside": "2", "g.h.leaf": "3"}
+	for key, value := range want {
+		if entries[0].Attrs[key] != value {
+			t.Errorf("属性 %s = %q, 期望 %q，全部属性: %v", key, entries[0].Attrs[key], value, entries[0].Attrs)
+		}
+	}
+}
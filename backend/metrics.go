@@ -0,0 +1,198 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const metricsPrefix = "isaac_downloader_"
+
+// latencyBuckets 请求耗时（到收到响应头为止）直方图的上界，单位秒
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics 下载引擎的运行指标，以 Prometheus 文本格式输出
+// 计数器只增不减，任务数量等瞬时值在抓取时根据任务状态计算
+type Metrics struct {
+	bytes     atomic.Int64
+	retries   atomic.Int64
+	stalls    atomic.Int64
+	failovers atomic.Int64
+
+	mu        sync.Mutex
+	hostBytes map[string]int64
+	requests  map[requestKey]int64
+	latency   map[string]*histogram
+	results   map[string]int64 // completed、failed、corrupt
+}
+
+type requestKey struct {
+	host string
+	code int // 0 表示请求未收到响应
+}
+
+type histogram struct {
+	counts []int64 // 与 latencyBuckets 一一对应，非累积
+	sum    float64
+	count  int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		hostBytes: make(map[string]int64),
+		requests:  make(map[requestKey]int64),
+		latency:   make(map[string]*histogram),
+		results:   make(map[string]int64),
+	}
+}
+
+func (m *Metrics) addBytes(host string, n int64) {
+	m.bytes.Add(n)
+	m.mu.Lock()
+	m.hostBytes[host] += n
+	m.mu.Unlock()
+}
+
+func (m *Metrics) observeRequest(host string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{host: host, code: code}]++
+
+	h := m.latency[host]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(latencyBuckets))}
+		m.latency[host] = h
+	}
+	sec := d.Seconds()
+	for i, bound := range latencyBuckets {
+		if sec <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+// Emit 实现 EventSink，统计文件的最终结果
+func (m *Metrics) Emit(ev Event) {
+	var result string
+	switch ev.Type {
+	case EventCompleted:
+		result = "completed"
+	case EventFailed:
+		result = "failed"
+		var invalid *ZipValidationError
+		if errors.As(ev.Err, &invalid) {
+			result = "corrupt"
+		}
+	default:
+		return
+	}
+	m.mu.Lock()
+	m.results[result]++
+	m.mu.Unlock()
+}
+
+// WritePrometheus 输出所有指标，tasks 用于计算各状态的任务数量
+func (m *Metrics) WritePrometheus(w io.Writer, tasks []*DownloadTask) {
+	statusCounts := map[DownloadStatus]int{
+		StatusPending: 0, StatusDownloading: 0, StatusPaused: 0, StatusExtracting: 0,
+		StatusCompleted: 0, StatusFailed: 0, StatusCorrupt: 0,
+	}
+	for _, task := range tasks {
+		statusCounts[task.GetStatus()]++
+	}
+
+	writeHeader(w, "bytes_total", "counter", "下载写入的总字节数")
+	fmt.Fprintf(w, "%sbytes_total %d\n", metricsPrefix, m.bytes.Load())
+	writeHeader(w, "retries_total", "counter", "因可重试错误重新发起请求的次数")
+	fmt.Fprintf(w, "%sretries_total %d\n", metricsPrefix, m.retries.Load())
+	writeHeader(w, "stalls_total", "counter", "连接卡死被中止的次数")
+	fmt.Fprintf(w, "%sstalls_total %d\n", metricsPrefix, m.stalls.Load())
+	writeHeader(w, "failovers_total", "counter", "切换到其他镜像的次数")
+	fmt.Fprintf(w, "%sfailovers_total %d\n", metricsPrefix, m.failovers.Load())
+
+	writeHeader(w, "tasks", "gauge", "各状态的文件数量")
+	for _, status := range sortedKeys(statusCounts) {
+		fmt.Fprintf(w, "%stasks{status=\"%s\"} %d\n", metricsPrefix, status, statusCounts[status])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(w, "files_total", "counter", "按最终结果统计的文件数")
+	for _, result := range sortedKeys(m.results) {
+		fmt.Fprintf(w, "%sfiles_total{result=\"%s\"} %d\n", metricsPrefix, result, m.results[result])
+	}
+
+	writeHeader(w, "host_bytes_total", "counter", "按主机统计的下载字节数")
+	for _, host := range sortedKeys(m.hostBytes) {
+		fmt.Fprintf(w, "%shost_bytes_total{host=\"%s\"} %d\n", metricsPrefix, escapeLabel(host), m.hostBytes[host])
+	}
+
+	writeHeader(w, "requests_total", "counter", "按主机和状态码统计的请求数，code 为 0 表示未收到响应")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].host != keys[j].host {
+			return keys[i].host < keys[j].host
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "%srequests_total{host=\"%s\",code=\"%d\"} %d\n", metricsPrefix, escapeLabel(k.host), k.code, m.requests[k])
+	}
+
+	writeHeader(w, "request_duration_seconds", "histogram", "从发出请求到收到响应头的耗时")
+	for _, host := range sortedKeys(m.latency) {
+		h := m.latency[host]
+		label := escapeLabel(host)
+		var cumulative int64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%srequest_duration_seconds_bucket{host=\"%s\",le=\"%g\"} %d\n", metricsPrefix, label, bound, cumulative)
+		}
+		fmt.Fprintf(w, "%srequest_duration_seconds_bucket{host=\"%s\",le=\"+Inf\"} %d\n", metricsPrefix, label, h.count)
+		fmt.Fprintf(w, "%srequest_duration_seconds_sum{host=\"%s\"} %g\n", metricsPrefix, label, h.sum)
+		fmt.Fprintf(w, "%srequest_duration_seconds_count{host=\"%s\"} %d\n", metricsPrefix, label, h.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, typ)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出引擎指标
+func (e *DownloadEngine) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.metrics.WritePrometheus(w, e.GetRunningTasks())
+	})
+}
+
+// Metrics 返回引擎的运行指标
+func (e *DownloadEngine) Metrics() *Metrics {
+	return e.metrics
+}
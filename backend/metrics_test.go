@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRecordDownloads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "missing.bin") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	host := mustHost(t, server.URL)
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	dir := t.TempDir()
+	ok := &DownloadTask{URL: server.URL + "/a.bin", LocalPath: filepath.Join(dir, "a.bin")}
+	missing := &DownloadTask{URL: server.URL + "/missing.bin", LocalPath: filepath.Join(dir, "missing.bin")}
+	engine.StartDownload(ok)
+	engine.StartDownload(missing)
+	waitForStatus(t, ok, 5*time.Second)
+	waitForStatus(t, missing, 5*time.Second)
+
+	metricsServer := httptest.NewServer(engine.MetricsHandler())
+	defer metricsServer.Close()
+
+	// 结果计数在状态更新后才记录，稍等片刻
+	var text string
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(metricsServer.URL)
+		if err != nil {
+			t.Fatalf("抓取指标失败: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		text = string(body)
+		if strings.Contains(text, `files_total{result="failed"} 1`) && strings.Contains(text, `files_total{result="completed"} 1`) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, want := range []string{
+		"isaac_downloader_bytes_total 10",
+		`isaac_downloader_host_bytes_total{host="` + host + `"} 10`,
+		`isaac_downloader_requests_total{host="` + host + `",code="200"} 1`,
+		`isaac_downloader_requests_total{host="` + host + `",code="404"} 1`,
+		`isaac_downloader_request_duration_seconds_count{host="` + host + `"} 2`,
+		`isaac_downloader_files_total{result="completed"} 1`,
+		`isaac_downloader_files_total{result="failed"} 1`,
+		`isaac_downloader_tasks{status="completed"} 1`,
+		"# TYPE isaac_downloader_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("指标中缺少 %q\n%s", want, text)
+		}
+	}
+}
+
+func mustHost(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("解析地址失败: %v", err)
+	}
+	return u.Host
+}
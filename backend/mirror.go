@@ -0,0 +1,149 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Segment 记录某个镜像提供的字节区间 [Start, End)
+type Segment struct {
+	Mirror string `json:"mirror"` // 已脱敏的镜像地址
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+}
+
+// candidateURLs 返回主地址和镜像地址，去重并保持原有顺序
+func candidateURLs(primary string, mirrors []string) []string {
+	seen := map[string]bool{primary: true}
+	result := []string{primary}
+	for _, m := range mirrors {
+		if m != "" && !seen[m] {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// CandidateURLs 返回文件的主地址和所有镜像地址
+func (f FileInfo) CandidateURLs() []string {
+	return candidateURLs(f.URL, f.Mirrors)
+}
+
+// prepareMirrors 首次下载前探测所有候选地址，按响应速度排序，不可用的排在最后
+func (e *DownloadEngine) prepareMirrors(ctx context.Context, task *DownloadTask, opts EngineOptions) {
+	task.mu.Lock()
+	ready := task.mirrors != nil
+	candidates := candidateURLs(task.URL, task.Mirrors)
+	task.mu.Unlock()
+	if ready {
+		return
+	}
+	if len(candidates) > 1 {
+		candidates = e.rankMirrors(ctx, task, candidates, opts)
+	}
+
+	task.mu.Lock()
+	task.mirrors = candidates
+	task.activeMirror = 0
+	task.mu.Unlock()
+}
+
+func (e *DownloadEngine) rankMirrors(ctx context.Context, task *DownloadTask, candidates []string, opts EngineOptions) []string {
+	probeCtx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout+opts.ReadTimeout)
+	defer cancel()
+
+	latency := make([]time.Duration, len(candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go func(i int, candidate string) {
+			defer wg.Done()
+			start := time.Now()
+			resp, err := e.probe(probeCtx, "GET", candidate, opts, task)
+			if err != nil {
+				latency[i] = -1
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				latency[i] = -1
+				return
+			}
+			latency[i] = time.Since(start)
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		la, lb := latency[order[a]], latency[order[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+		return la < lb
+	})
+
+	ranked := make([]string, len(candidates))
+	for i, idx := range order {
+		ranked[i] = candidates[idx]
+	}
+	return ranked
+}
+
+// currentURL 返回当前使用的镜像地址
+func (t *DownloadTask) currentURL() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.mirrors) == 0 {
+		return t.URL
+	}
+	return t.mirrors[t.activeMirror]
+}
+
+func (t *DownloadTask) mirrorCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.mirrors)
+}
+
+// nextMirror 切换到下一个镜像，下一次请求从当前已写入的位置续传
+func (t *DownloadTask) nextMirror() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.mirrors) > 1 {
+		t.activeMirror = (t.activeMirror + 1) % len(t.mirrors)
+	}
+}
+
+// canFailover 本地文件错误换镜像也无济于事，其余错误都可以尝试其他镜像
+func canFailover(err error) bool {
+	var pathErr *os.PathError
+	return !errors.As(err, &pathErr)
+}
+
+// recordSegment 记录一次请求写入的字节区间，与上一段来自同一镜像且相邻时合并
+func (t *DownloadTask) recordSegment(mirror string, start, end int64) {
+	if end <= start {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	mirror = RedactURL(mirror)
+	if n := len(t.Segments); n > 0 {
+		last := &t.Segments[n-1]
+		if last.Mirror == mirror && last.End == start {
+			last.End = end
+			return
+		}
+	}
+	t.Segments = append(t.Segments, Segment{Mirror: mirror, Start: start, End: end})
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirrorFailoverMidDownload(t *testing.T) {
+	// 镜像 A 响应快，但写出一半后断开连接
+	mirrorA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=0-0" {
+			w.WriteHeader(http.StatusPartialContent)
+			return
+		}
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer mirrorA.Close()
+
+	// 镜像 B 探测较慢，但能从断点续传
+	mirrorB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=0-0" {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusPartialContent)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=5-" {
+			t.Errorf("期望从第 5 字节续传，实际 Range: %q", r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", "bytes 5-9/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("world"))
+	}))
+	defer mirrorB.Close()
+
+	opts := DefaultEngineOptions()
+	opts.MaxRetries = 0
+	engine := NewDownloadEngine(opts)
+
+	task := &DownloadTask{
+		URL:       mirrorB.URL + "/e.bin?sig=1",
+		Mirrors:   []string{mirrorA.URL + "/e.bin?sig=2"},
+		LocalPath: filepath.Join(t.TempDir(), "e.bin"),
+	}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if data, _ := os.ReadFile(task.LocalPath); string(data) != "helloworld" {
+		t.Errorf("文件内容不正确: %q", data)
+	}
+
+	want := []Segment{
+		{Mirror: mirrorA.URL + "/e.bin", Start: 0, End: 5},
+		{Mirror: mirrorB.URL + "/e.bin", Start: 5, End: 10},
+	}
+	if len(task.Segments) != len(want) {
+		t.Fatalf("期望 %d 个区间，实际: %+v", len(want), task.Segments)
+	}
+	for i := range want {
+		if task.Segments[i] != want[i] {
+			t.Errorf("区间 %d 期望 %+v，实际 %+v", i, want[i], task.Segments[i])
+		}
+		if strings.Contains(task.Segments[i].Mirror, "sig=") {
+			t.Errorf("区间记录不应包含签名参数: %s", task.Segments[i].Mirror)
+		}
+	}
+}
+
+// 任务的自定义请求头只发送给主地址所在主机，探测和下载镜像时都不携带
+func TestMirrorRequestsOmitPrimaryHeaders(t *testing.T) {
+	var primaryAuth atomic.Value
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryAuth.Store(r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	var leaked atomic.Bool
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get("X-Env") != "" {
+			leaked.Store(true)
+		}
+		w.Write([]byte("data"))
+	}))
+	defer mirror.Close()
+
+	opts := DefaultEngineOptions()
+	opts.MaxRetries = 0
+	task := &DownloadTask{
+		URL:       primary.URL + "/f.bin",
+		Mirrors:   []string{mirror.URL + "/f.bin"},
+		LocalPath: filepath.Join(t.TempDir(), "f.bin"),
+		Headers:   map[string]string{"Authorization": "Bearer secret", "X-Env": "prod"},
+	}
+	NewDownloadEngine(opts).StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望下载完成，实际: %s", status)
+	}
+	if leaked.Load() {
+		t.Errorf("镜像请求不应携带主地址的自定义请求头")
+	}
+	if got := primaryAuth.Load(); got != "Bearer secret" {
+		t.Errorf("主地址请求应携带自定义请求头，实际: %v", got)
+	}
+}
+
+func TestCandidateURLs(t *testing.T) {
+	file := FileInfo{URL: "https://a/x", Mirrors: []string{"https://b/x", "", "https://a/x", "https://c/x"}}
+	got := file.CandidateURLs()
+	if strings.Join(got, ",") != "https://a/x,https://b/x,https://c/x" {
+		t.Errorf("候选地址去重结果不正确: %v", got)
+	}
+}
+
+// 脚本中的 Cookie 按 host-only 作用域发送，不同主机的镜像收不到
+func TestRequestCookiesScopedToPrimaryHost(t *testing.T) {
+	task := &DownloadTask{URL: "https://storage.example.com/a/f.bin", Cookies: map[string]string{"session": "s1"}}
+	for rawURL, want := range map[string]bool{
+		"https://storage.example.com/b/f.bin":   true,
+		"http://storage.example.com:8080/f.bin": true, // Cookie 不区分端口
+		"https://mirror.example.net/f.bin":      false,
+		"https://sub.storage.example.com/f.bin": false,
+		"https://evilstorage.example.com/f.bin": false,
+	} {
+		u, _ := url.Parse(rawURL)
+		got := task.requestCookies(u)
+		if sent := len(got) == 1 && got[0].Value == "s1"; sent != want {
+			t.Errorf("%s: 期望发送 Cookie=%v，实际 %v", rawURL, want, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package backend
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultUserAgent  = "isaac-downloader/1.0"
+	DefaultBufferSize = 32 * 1024
+)
+
+// EngineOptions 下载引擎的可调参数，零值字段在 normalize 时使用默认值
+type EngineOptions struct {
+	MaxConcurrent       int
+	ConnectTimeout      time.Duration // TCP 建连超时
+	TLSHandshakeTimeout time.Duration // TLS 握手超时
+	ReadTimeout         time.Duration // 等待响应头以及空闲连接保留的最长时间
+	UserAgent           string
+	BufferSize          int           // 单次读取缓冲区字节数
+	MaxRetries          int           // 失败后的最大重试次数，0 表示不重试
+	MaxConnsPerHost     int           // 每个主机的最大连接数，0 表示不限制
+	StallTimeout        time.Duration // 连续无数据到达多久视为连接卡死
+	Proxy               ProxyConfig
+	TLS                 TLSConfig
+
+	ValidateZip               bool // 下载完成后校验 .zip 结构和 CRC
+	RedownloadCorrupt         bool // 校验失败时删除并自动重新下载一次
+	AutoExtract               bool // 下载完成后自动解压 .zip 到同级目录
+	DeleteArchiveAfterExtract bool // 解压成功后删除压缩包
+
+	ConflictPolicy ConflictPolicy // 目标路径已有文件时的处理策略，为空时按 resume 处理
+}
+
+func DefaultEngineOptions() EngineOptions {
+	return EngineOptions{
+		MaxConcurrent:       3,
+		ConnectTimeout:      30 * time.Second,
+		TLSHandshakeTimeout: 15 * time.Second,
+		ReadTimeout:         60 * time.Second,
+		UserAgent:           DefaultUserAgent,
+		BufferSize:          DefaultBufferSize,
+		MaxRetries:          3,
+		StallTimeout:        60 * time.Second,
+		ValidateZip:         true,
+		ConflictPolicy:      ConflictResume,
+	}
+}
+
+func (o EngineOptions) normalize() EngineOptions {
+	def := DefaultEngineOptions()
+	if o.MaxConcurrent <= 0 {
+		o.MaxConcurrent = def.MaxConcurrent
+	}
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = def.ConnectTimeout
+	}
+	if o.TLSHandshakeTimeout <= 0 {
+		o.TLSHandshakeTimeout = def.TLSHandshakeTimeout
+	}
+	if o.ReadTimeout <= 0 {
+		o.ReadTimeout = def.ReadTimeout
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = def.UserAgent
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = def.BufferSize
+	}
+	if o.StallTimeout <= 0 {
+		o.StallTimeout = def.StallTimeout
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.MaxConnsPerHost < 0 {
+		o.MaxConnsPerHost = 0
+	}
+	return o
+}
+
+// newTransport 按选项构建 RoundTripper，配置了 TLS 主机覆盖时按主机分流
+func newTransport(opts EngineOptions) http.RoundTripper {
+	base := newHTTPTransport(opts)
+	if opts.TLS.isZero() {
+		return base
+	}
+
+	config, err := opts.TLS.baseConfig()
+	if err != nil {
+		return errTransport{err: err}
+	}
+	base.TLSClientConfig = config
+
+	if len(opts.TLS.Hosts) == 0 {
+		return base
+	}
+	routing := &hostRoutingTransport{base: base, hosts: make(map[string]*http.Transport)}
+	for _, override := range opts.TLS.Hosts {
+		config, err := opts.TLS.hostConfig(override)
+		if err != nil {
+			return errTransport{err: err}
+		}
+		rt := newHTTPTransport(opts)
+		rt.TLSClientConfig = config
+		host, _ := tlsHostKey(override.Host)
+		routing.hosts[host] = rt
+	}
+	return routing
+}
+
+func newHTTPTransport(opts EngineOptions) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 opts.Proxy.ProxyFunc(),
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ReadTimeout,
+		IdleConnTimeout:       opts.ReadTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxConcurrent,
+		ForceAttemptHTTP2:     true,
+	}
+}
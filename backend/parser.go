@@ -3,20 +3,65 @@ package backend
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type FileInfo struct {
-	URL  string `json:"url"`
-	Path string `json:"path"`
+	URL     string            `json:"url"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"` // 覆盖任务级同名请求头
+	Cookies map[string]string `json:"cookies,omitempty"` // 覆盖任务级同名 Cookie
+	Mirrors []string          `json:"mirrors,omitempty"` // 备用下载地址，与 URL 内容完全相同
+	Size    int64             `json:"size,omitempty"`    // 文件大小，脚本未提供时为 0
+	SHA256  string            `json:"sha256,omitempty"`  // 十六进制 SHA-256，脚本未提供时为空
 }
 
 type TaskInfo struct {
-	TaskId   int64      `json:"taskId"`
-	TaskName string     `json:"taskName"`
-	Files    []FileInfo `json:"files"`
+	TaskId   int64             `json:"taskId"`
+	TaskName string            `json:"taskName"`
+	Files    []FileInfo        `json:"files"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Cookies  map[string]string `json:"cookies,omitempty"`
+	Script   string            `json:"-"` // 任务来自的脚本路径
+}
+
+// RequestHeaders 合并任务级和文件级请求头，文件级优先，头名称不区分大小写
+func (t TaskInfo) RequestHeaders(file FileInfo) map[string]string {
+	return mergeStringMaps(canonicalHeaders(t.Headers), canonicalHeaders(file.Headers))
+}
+
+func canonicalHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[http.CanonicalHeaderKey(k)] = v
+	}
+	return result
+}
+
+// RequestCookies 合并任务级和文件级 Cookie，文件级优先
+func (t TaskInfo) RequestCookies(file FileInfo) map[string]string {
+	return mergeStringMaps(t.Cookies, file.Cookies)
+}
+
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
 }
 
 type DownloaderConfig struct {
@@ -41,6 +86,7 @@ func ParseScript(content string, scriptFileName string) (*DownloaderConfig, erro
 
 	// 从文件路径提取任务名称（去掉ID和时间戳）
 	for i := range config.Tasks {
+		config.Tasks[i].Script = scriptFileName
 		if len(config.Tasks[i].Files) > 0 {
 			path := config.Tasks[i].Files[0].Path
 			// 格式: "演示用抓取任务_2013529099792277505_20260202_135655/xxx.zip"
@@ -63,6 +109,23 @@ func ParseScript(content string, scriptFileName string) (*DownloaderConfig, erro
 	return &config, nil
 }
 
+// captureDirPattern 抓取目录名: 任务名_任务ID_YYYYMMDD_HHMMSS，任务名本身可以包含下划线
+var captureDirPattern = regexp.MustCompile(`^(.+)_(\d+)_(\d{8}_\d{6})$`)
+
+// ParseCaptureDirName 从抓取目录名中解析任务名称、任务 ID 和抓取时间（按本地时区）
+// 目录名不符合格式时 ok 为 false
+func ParseCaptureDirName(name string) (taskName, taskId string, capturedAt time.Time, ok bool) {
+	m := captureDirPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", time.Time{}, false
+	}
+	capturedAt, err := time.ParseInLocation("20060102_150405", m[3], time.Local)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+	return m[1], m[2], capturedAt, true
+}
+
 func extractJsonFromScript(content string) (string, error) {
 	// 支持 PowerShell: $FilesJson = '...'
 	// 支持 Batch: set FilesJson=...
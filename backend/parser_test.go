@@ -4,16 +4,18 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseRealScript(t *testing.T) {
 	// 读取真实脚本文件
-	content, err := os.ReadFile("../build/bin/演示用抓取任务_20260202_135655.ps1")
+	scriptPath := "../build/bin/演示用抓取任务_20260202_135655.ps1"
+	content, err := os.ReadFile(scriptPath)
 	if err != nil {
 		t.Fatalf("读取脚本文件失败: %v", err)
 	}
 
-	config, err := ParseScript(string(content))
+	config, err := ParseScript(string(content), scriptPath)
 	if err != nil {
 		t.Fatalf("ParseScript 失败: %v", err)
 	}
@@ -49,3 +51,44 @@ func TestParseRealScript(t *testing.T) {
 		}
 	}
 }
+
+func TestParseScriptHeadersAndCookies(t *testing.T) {
+	script := `$FilesJson = '{"tasks":[{"taskId":1,"headers":{"authorization":"Bearer task","X-Env":"prod"},` +
+		`"cookies":{"session":"s1"},"files":[` +
+		`{"url":"https://a/1.zip","path":"demo_1_20260202_135655/1.zip"},` +
+		`{"url":"https://a/2.zip","path":"demo_1_20260202_135655/2.zip","headers":{"Authorization":"Bearer file"},"cookies":{"session":"s2"}}]}]}'`
+
+	config, err := ParseScript(script, "demo.ps1")
+	if err != nil {
+		t.Fatalf("ParseScript 失败: %v", err)
+	}
+
+	task := config.Tasks[0]
+	first := task.RequestHeaders(task.Files[0])
+	if first["Authorization"] != "Bearer task" || first["X-Env"] != "prod" {
+		t.Errorf("第一个文件应继承任务级请求头: %v", first)
+	}
+
+	second := task.RequestHeaders(task.Files[1])
+	if second["Authorization"] != "Bearer file" || len(second) != 2 {
+		t.Errorf("文件级请求头应覆盖任务级同名请求头: %v", second)
+	}
+	if cookies := task.RequestCookies(task.Files[1]); cookies["session"] != "s2" {
+		t.Errorf("文件级 Cookie 应覆盖任务级: %v", cookies)
+	}
+}
+
+func TestParseCaptureDirName(t *testing.T) {
+	name, id, at, ok := ParseCaptureDirName("演示_用抓取任务_2013529099792277505_20260202_135655")
+	if !ok || name != "演示_用抓取任务" || id != "2013529099792277505" {
+		t.Fatalf("解析结果不正确: %q %q %v", name, id, ok)
+	}
+	if want := time.Date(2026, 2, 2, 13, 56, 55, 0, time.Local); !at.Equal(want) {
+		t.Errorf("抓取时间 = %v, 期望 %v", at, want)
+	}
+	for _, bad := range []string{"downloads", "任务_123_20260202", "任务_abc_20260202_135655", "任务_123_20261302_135655"} {
+		if _, _, _, ok := ParseCaptureDirName(bad); ok {
+			t.Errorf("%q 不应被识别为抓取目录", bad)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlanAction 开始下载时对单个文件的处理方式
+type PlanAction string
+
+const (
+	PlanDownload  PlanAction = "download"  // 本地不存在，从头下载
+	PlanResume    PlanAction = "resume"    // 从本地已有大小续传
+	PlanSkip      PlanAction = "skip"      // 已完整或不会下载
+	PlanOverwrite PlanAction = "overwrite" // 本地已有文件，但会从头重新写入
+	PlanRename    PlanAction = "rename"    // 保留本地已有文件，另存为带序号的新文件
+	PlanAsk       PlanAction = "ask"       // 开始时询问用户
+)
+
+type PlanOptions struct {
+	// Probe 为 true 时请求每个文件的远程大小，并判断服务器是否支持续传
+	Probe bool
+	// Completed 返回 true 的地址本次会话已下载完成，开始下载时会跳过
+	Completed func(url string) bool
+	// Selected 为空时全部选中；未选中的文件跳过，不参与冲突检查和探测
+	Selected func(task TaskInfo, file FileInfo) bool
+	// Policy 本地已有文件时的处理策略，为空时按 resume 处理
+	Policy ConflictPolicy
+}
+
+type PlanItem struct {
+	TaskId     string     `json:"taskId"`
+	TaskName   string     `json:"taskName"`
+	Path       string     `json:"path"`   // 脚本中的相对路径
+	Target     string     `json:"target"` // 解析后的本地绝对路径
+	URL        string     `json:"url"`    // 已去除签名参数
+	Action     PlanAction `json:"action"`
+	Reason     string     `json:"reason,omitempty"`
+	LocalSize  int64      `json:"localSize"`  // 本地不存在时为 -1
+	RemoteSize int64      `json:"remoteSize"` // 未探测或服务器未返回时为 -1
+	Resumable  bool       `json:"resumable"`  // 服务器支持 Range 请求
+	ProbeError string     `json:"probeError,omitempty"`
+	Conflict   string     `json:"conflict,omitempty"`  // 目标路径与其他文件冲突或不可写入
+	Duplicate  bool       `json:"duplicate,omitempty"` // 与前面的文件地址相同
+	Selected   bool       `json:"selected"`
+
+	url     string
+	headers map[string]string
+	cookies map[string]string
+}
+
+type PlanSummary struct {
+	Files      int   `json:"files"`
+	Download   int   `json:"download"`
+	Resume     int   `json:"resume"`
+	Skip       int   `json:"skip"`
+	Overwrite  int   `json:"overwrite"`
+	Rename     int   `json:"rename"`
+	Ask        int   `json:"ask"`
+	Conflicts  int   `json:"conflicts"`
+	Duplicates int   `json:"duplicates"`
+	ProbeFails int   `json:"probeFails"`
+	Bytes      int64 `json:"bytes"` // 预计需要传输的字节数，只统计已知远程大小的文件
+}
+
+// DownloadPlan 开始下载前的预演结果，不会修改磁盘上的任何文件
+type DownloadPlan struct {
+	Root      string      `json:"root"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Probed    bool        `json:"probed"`
+	Summary   PlanSummary `json:"summary"`
+	Items     []PlanItem  `json:"items"`
+}
+
+// FileTarget 文件在下载目录中的目标路径，以及开始下载时不会下载它的原因
+type FileTarget struct {
+	Task      TaskInfo
+	File      FileInfo
+	Target    string // filepath.Join(root, File.Path)
+	Selected  bool
+	Duplicate bool   // 与前面选中的文件地址相同，只下载一次
+	Conflict  string // 目标路径超出下载目录、与其他文件相同或是已存在的目录
+	LocalSize int64  // 本地不存在时为 -1
+}
+
+// Skipped 返回 true 时开始下载会跳过该文件
+func (t *FileTarget) Skipped() bool {
+	return !t.Selected || t.Duplicate || t.Conflict != ""
+}
+
+// ResolveTargets 按脚本顺序解析每个文件的目标路径，标记重复地址、目标路径冲突和超出下载目录的文件
+// 预演和开始下载都通过它判断，两者跳过的文件保持一致
+func ResolveTargets(root string, tasks []TaskInfo, selected func(task TaskInfo, file FileInfo) bool) []FileTarget {
+	var result []FileTarget
+	seenURL := make(map[string]bool)
+	targets := make(map[string]int) // 规范化的目标路径 -> 第一个使用它的条目下标
+	for _, task := range tasks {
+		for _, file := range task.Files {
+			item := FileTarget{
+				Task:      task,
+				File:      file,
+				Target:    filepath.Join(root, file.Path),
+				Selected:  selected == nil || selected(task, file),
+				LocalSize: -1,
+			}
+			if item.Selected {
+				item.Duplicate = seenURL[file.URL]
+				seenURL[file.URL] = true
+			}
+
+			if !item.Selected {
+				// 未选中的文件不会写入磁盘，不参与冲突检查
+			} else if !isWithin(root, item.Target) {
+				item.Conflict = "路径超出下载目录"
+			} else if !item.Duplicate {
+				// Windows 和 macOS 默认不区分大小写，按不区分大小写判断冲突
+				key := strings.ToLower(item.Target)
+				if first, ok := targets[key]; ok {
+					item.Conflict = "与 " + result[first].File.Path + " 的目标路径相同"
+					if result[first].Conflict == "" {
+						result[first].Conflict = "与 " + item.File.Path + " 的目标路径相同"
+					}
+				} else {
+					targets[key] = len(result)
+				}
+			}
+
+			if info, err := os.Stat(item.Target); err == nil {
+				if info.IsDir() {
+					if item.Conflict == "" {
+						item.Conflict = "目标路径是已存在的目录"
+					}
+				} else {
+					item.LocalSize = info.Size()
+				}
+			}
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// BuildPlan 解析每个文件的目标路径并检查本地文件，按引擎的续传规则推断开始下载时的处理方式
+func (e *DownloadEngine) BuildPlan(ctx context.Context, root string, tasks []TaskInfo, opts PlanOptions) *DownloadPlan {
+	plan := &DownloadPlan{Root: root, CreatedAt: time.Now(), Probed: opts.Probe, Items: []PlanItem{}}
+
+	for _, target := range ResolveTargets(root, tasks, opts.Selected) {
+		task, file := target.Task, target.File
+		plan.Items = append(plan.Items, PlanItem{
+			TaskId:     strconv.FormatInt(task.TaskId, 10),
+			TaskName:   task.TaskName,
+			Path:       file.Path,
+			Target:     target.Target,
+			URL:        RedactURL(file.URL),
+			LocalSize:  target.LocalSize,
+			RemoteSize: -1,
+			Conflict:   target.Conflict,
+			Duplicate:  target.Duplicate,
+			Selected:   target.Selected,
+			url:        file.URL,
+			headers:    task.RequestHeaders(file),
+			cookies:    task.RequestCookies(file),
+		})
+	}
+
+	if opts.Probe {
+		e.probePlan(ctx, plan)
+	}
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		item.Action, item.Reason = planAction(item, opts)
+	}
+	plan.summarize()
+	return plan
+}
+
+// planAction 与 resolveConflict 和 attempt 的逻辑保持一致
+// 续传时本地文件大小作为 Range 起点，服务器返回 416 视为已完成，返回 200 则截断重写
+func planAction(item *PlanItem, opts PlanOptions) (PlanAction, string) {
+	switch {
+	case !item.Selected:
+		return PlanSkip, "未选中"
+	case item.Duplicate:
+		return PlanSkip, "与前面的文件地址相同，只下载一次"
+	case item.Conflict != "":
+		return PlanSkip, item.Conflict
+	case opts.Completed != nil && opts.Completed(item.url):
+		return PlanSkip, "本次已下载完成"
+	case item.LocalSize <= 0:
+		if item.LocalSize < 0 && IsZipFile(item.Target) && dirExists(ExtractDir(item.Target)) {
+			return PlanDownload, "压缩包不存在，但解压目录已存在"
+		}
+		return PlanDownload, ""
+	}
+
+	switch opts.Policy {
+	case ConflictSkipSameSize:
+		if item.RemoteSize == item.LocalSize {
+			return PlanSkip, "本地文件大小与远程一致"
+		}
+		if item.RemoteSize < 0 {
+			return PlanResume, "远程大小未知，不删除已有文件，从本地已有大小续传"
+		}
+		return PlanOverwrite, "本地文件大小与远程不一致，将删除后重新下载"
+	case ConflictOverwrite:
+		return PlanOverwrite, "将删除已有文件后重新下载"
+	case ConflictRename:
+		if renamed, err := availablePath(item.Target); err == nil {
+			return PlanRename, "保留已有文件，另存为 " + filepath.Base(renamed)
+		}
+		return PlanRename, "保留已有文件，另存为新文件"
+	case ConflictAsk:
+		return PlanAsk, "本地已有文件，开始下载时询问"
+	}
+
+	switch {
+	case item.RemoteSize < 0:
+		return PlanResume, "远程大小未知，从本地已有大小续传"
+	case item.LocalSize == item.RemoteSize:
+		return PlanSkip, "本地文件大小与远程一致"
+	case item.LocalSize > item.RemoteSize:
+		return PlanSkip, "本地文件比远程大，可能来自其他版本"
+	case !item.Resumable:
+		return PlanOverwrite, "服务器不支持续传，将从头下载"
+	default:
+		return PlanResume, ""
+	}
+}
+
+func (p *DownloadPlan) summarize() {
+	s := PlanSummary{Files: len(p.Items)}
+	for _, item := range p.Items {
+		switch item.Action {
+		case PlanDownload:
+			s.Download++
+		case PlanResume:
+			s.Resume++
+		case PlanSkip:
+			s.Skip++
+		case PlanOverwrite:
+			s.Overwrite++
+		case PlanRename:
+			s.Rename++
+		case PlanAsk:
+			s.Ask++
+		}
+		if item.Conflict != "" {
+			s.Conflicts++
+		}
+		if item.Duplicate {
+			s.Duplicates++
+		}
+		if item.ProbeError != "" {
+			s.ProbeFails++
+		}
+		if item.RemoteSize >= 0 {
+			switch item.Action {
+			case PlanDownload, PlanOverwrite, PlanRename:
+				s.Bytes += item.RemoteSize
+			case PlanResume:
+				s.Bytes += item.RemoteSize - item.LocalSize
+			}
+		}
+	}
+	p.Summary = s
+}
+
+// probePlan 按引擎并发数探测远程大小，重复和冲突的条目不探测
+func (e *DownloadEngine) probePlan(ctx context.Context, plan *DownloadPlan) {
+	opts := e.Options()
+	workers := opts.MaxConcurrent
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *PlanItem)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				task := &DownloadTask{URL: item.url, Headers: item.headers, Cookies: item.cookies}
+				size, resumable, err := e.remoteSize(ctx, item.url, task, opts)
+				if err != nil {
+					item.ProbeError = RedactText(err.Error())
+					continue
+				}
+				item.RemoteSize, item.Resumable = size, resumable
+			}
+		}()
+	}
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		if !item.Selected || item.Duplicate || item.Conflict != "" {
+			continue
+		}
+		select {
+		case jobs <- item:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// remoteSize 先发送 HEAD，被拒绝时改用只请求首字节的 GET，size 为 -1 表示服务器未返回大小
+func (e *DownloadEngine) remoteSize(ctx context.Context, rawURL string, task *DownloadTask, opts EngineOptions) (size int64, resumable bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout+opts.ReadTimeout)
+	defer cancel()
+
+	resp, err := e.probe(ctx, "HEAD", rawURL, opts, task)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusForbidden) {
+		resp.Body.Close()
+		resp, err = e.probe(ctx, "GET", rawURL, opts, task)
+	}
+	if err != nil {
+		return -1, false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return contentRangeTotal(resp.Header.Get("Content-Range")), true, nil
+	case resp.StatusCode < 400:
+		size = -1
+		if resp.ContentLength >= 0 {
+			size = resp.ContentLength
+		}
+		return size, strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"), nil
+	default:
+		return -1, false, &httpStatusError{Code: resp.StatusCode}
+	}
+}
+
+// isWithin 判断 path 是否位于 root 目录内
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "missing.bin"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "norange.bin"):
+			// 没有 Accept-Ranges，续传时服务器会返回 200
+			w.Header().Set("Content-Length", "10")
+		case r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "signed.bin"):
+			// 预签名地址拒绝 HEAD
+			w.WriteHeader(http.StatusForbidden)
+		case r.Method == http.MethodGet:
+			w.Header().Set("Content-Range", "bytes 0-0/10")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("0"))
+		default:
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "10")
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	writeCaptureFile(t, filepath.Join(root, "cap", "partial.bin"), 4)
+	writeCaptureFile(t, filepath.Join(root, "cap", "done.bin"), 10)
+	writeCaptureFile(t, filepath.Join(root, "cap", "norange.bin"), 4)
+	writeCaptureFile(t, filepath.Join(root, "cap", "signed.bin"), 4)
+	writeCaptureFile(t, filepath.Join(root, "cap", "larger.bin"), 12)
+
+	tasks := []TaskInfo{{TaskId: 1, TaskName: "演示任务", Files: []FileInfo{
+		{URL: server.URL + "/new.bin", Path: "cap/new.bin"},
+		{URL: server.URL + "/partial.bin", Path: "cap/partial.bin"},
+		{URL: server.URL + "/done.bin", Path: "cap/done.bin"},
+		{URL: server.URL + "/norange.bin", Path: "cap/norange.bin"},
+		{URL: server.URL + "/signed.bin?X-Amz-Signature=secret", Path: "cap/signed.bin"},
+		{URL: server.URL + "/larger.bin", Path: "cap/larger.bin"},
+		{URL: server.URL + "/missing.bin", Path: "cap/missing.bin"},
+		{URL: server.URL + "/new.bin", Path: "cap/new-copy.bin"},
+		{URL: server.URL + "/a.bin", Path: "cap/Same.bin"},
+		{URL: server.URL + "/b.bin", Path: "cap/same.bin"},
+		{URL: server.URL + "/c.bin", Path: "../escape.bin"},
+		{URL: server.URL + "/finished.bin", Path: "cap/finished.bin"},
+	}}}
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	plan := engine.BuildPlan(context.Background(), root, tasks, PlanOptions{
+		Probe:     true,
+		Completed: func(url string) bool { return strings.HasSuffix(url, "finished.bin") },
+	})
+
+	want := map[string]PlanAction{
+		"cap/new.bin":      PlanDownload,
+		"cap/partial.bin":  PlanResume,
+		"cap/done.bin":     PlanSkip,
+		"cap/norange.bin":  PlanOverwrite,
+		"cap/signed.bin":   PlanResume,
+		"cap/larger.bin":   PlanSkip,
+		"cap/missing.bin":  PlanDownload,
+		"cap/new-copy.bin": PlanSkip,
+		"cap/Same.bin":     PlanSkip,
+		"cap/same.bin":     PlanSkip,
+		"../escape.bin":    PlanSkip,
+		"cap/finished.bin": PlanSkip,
+	}
+	for _, item := range plan.Items {
+		if item.Action != want[item.Path] {
+			t.Errorf("%s: 处理方式 = %s (%s), 期望 %s", item.Path, item.Action, item.Reason, want[item.Path])
+		}
+		if strings.Contains(item.URL, "secret") {
+			t.Errorf("地址应去除签名: %s", item.URL)
+		}
+	}
+
+	byPath := make(map[string]PlanItem)
+	for _, item := range plan.Items {
+		byPath[item.Path] = item
+	}
+	if item := byPath["cap/signed.bin"]; item.RemoteSize != 10 || !item.Resumable {
+		t.Errorf("HEAD 被拒绝时应通过 Range GET 获取大小: %+v", item)
+	}
+	if item := byPath["cap/missing.bin"]; item.ProbeError == "" {
+		t.Errorf("探测失败应记录错误: %+v", item)
+	}
+	if !byPath["cap/new-copy.bin"].Duplicate || byPath["cap/Same.bin"].Conflict == "" || byPath["cap/same.bin"].Conflict == "" {
+		t.Errorf("应标记重复地址和大小写不同的同名路径")
+	}
+
+	s := plan.Summary
+	if s.Files != 12 || s.Download != 2 || s.Resume != 2 || s.Overwrite != 1 || s.Skip != 7 ||
+		s.Duplicates != 1 || s.Conflicts != 3 || s.ProbeFails != 1 {
+		t.Errorf("汇总不正确: %+v", s)
+	}
+	// new 10 + partial 6 + norange 10 + signed 6
+	if s.Bytes != 32 {
+		t.Errorf("预计传输字节数 = %d, 期望 32", s.Bytes)
+	}
+}
+
+func TestBuildPlanWithoutProbe(t *testing.T) {
+	root := t.TempDir()
+	writeCaptureFile(t, filepath.Join(root, "cap", "partial.bin"), 4)
+	tasks := []TaskInfo{{Files: []FileInfo{
+		{URL: "http://127.0.0.1:1/partial.bin", Path: "cap/partial.bin"},
+		{URL: "http://127.0.0.1:1/empty.bin", Path: "cap/empty.bin"},
+	}}}
+
+	plan := NewDownloadEngine(DefaultEngineOptions()).BuildPlan(context.Background(), root, tasks, PlanOptions{})
+	if plan.Items[0].Action != PlanResume || plan.Items[0].RemoteSize != -1 || plan.Items[1].Action != PlanDownload {
+		t.Errorf("未探测时应按本地文件判断: %+v", plan.Items)
+	}
+}
+
+func TestContentRangeTotal(t *testing.T) {
+	for header, want := range map[string]int64{
+		"bytes 5-9/10":   10,
+		"bytes 0-0/1234": 1234,
+		"bytes 0-9/*":    -1,
+		"":               -1,
+	} {
+		if got := contentRangeTotal(header); got != want {
+			t.Errorf("contentRangeTotal(%q) = %d, 期望 %d", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EventProgressBatch 合并后的进度快照，Data 为 *ProgressSnapshot
+const EventProgressBatch EventType = "progressBatch"
+
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// ProgressTotals 所有任务的总体进度，只统计已知大小的任务
+type ProgressTotals struct {
+	Downloaded int64   `json:"downloaded"`
+	Total      int64   `json:"total"`
+	Speed      int64   `json:"speed"`
+	Percentage float64 `json:"percentage"`
+	Active     int     `json:"active"` // 正在下载或解压的文件数
+}
+
+// SummarizeProgress 汇总任务列表的总体进度
+func SummarizeProgress(tasks []*DownloadTask) ProgressTotals {
+	var totals ProgressTotals
+	for _, task := range tasks {
+		task.mu.Lock()
+		// 只统计已开始下载的任务（TotalBytes > 0）
+		if task.TotalBytes > 0 {
+			totals.Downloaded += task.DownloadedBytes
+			totals.Total += task.TotalBytes
+		}
+		totals.Speed += task.Speed
+		if task.Status == StatusDownloading || task.Status == StatusExtracting {
+			totals.Active++
+		}
+		task.mu.Unlock()
+	}
+	if totals.Total > 0 {
+		totals.Percentage = float64(totals.Downloaded) / float64(totals.Total) * 100
+	}
+	return totals
+}
+
+// ProgressSnapshot 一次合并推送的内容：上次推送后有变化的任务及总体进度
+type ProgressSnapshot struct {
+	ProgressTotals
+	Tasks []map[string]any `json:"tasks"`
+}
+
+// ProgressAggregator 收集各任务的 progress 事件，按固定间隔合并为一个 progressBatch 事件
+// 并发很高、文件很多时，避免前端被逐个任务的进度事件淹没
+type ProgressAggregator struct {
+	mu       sync.Mutex
+	out      EventSink
+	source   func() []*DownloadTask
+	interval time.Duration
+	ticker   *time.Ticker
+	dirty    map[*DownloadTask]struct{}
+}
+
+// NewProgressAggregator 合并结果发往 out，source 提供计算总体进度的全部任务
+func NewProgressAggregator(out EventSink, interval time.Duration, source func() []*DownloadTask) *ProgressAggregator {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	return &ProgressAggregator{
+		out:      out,
+		source:   source,
+		interval: interval,
+		dirty:    make(map[*DownloadTask]struct{}),
+	}
+}
+
+// Emit 实现 EventSink，只记录发生变化的任务
+func (p *ProgressAggregator) Emit(ev Event) {
+	if ev.Task == nil || ev.Type == EventProgressBatch {
+		return
+	}
+	p.mu.Lock()
+	p.dirty[ev.Task] = struct{}{}
+	p.mu.Unlock()
+}
+
+// SetInterval 调整推送间隔，运行中立即生效
+func (p *ProgressAggregator) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = interval
+	if p.ticker != nil {
+		p.ticker.Reset(interval)
+	}
+}
+
+// Run 按间隔推送快照，直到 ctx 取消
+func (p *ProgressAggregator) Run(ctx context.Context) {
+	p.mu.Lock()
+	p.ticker = time.NewTicker(p.interval)
+	ticker := p.ticker
+	p.mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Flush()
+		}
+	}
+}
+
+// Flush 立即推送一次快照，没有任何变化时不推送
+func (p *ProgressAggregator) Flush() {
+	p.mu.Lock()
+	if len(p.dirty) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	changed := make([]*DownloadTask, 0, len(p.dirty))
+	for task := range p.dirty {
+		changed = append(changed, task)
+	}
+	p.dirty = make(map[*DownloadTask]struct{})
+	p.mu.Unlock()
+
+	snapshot := &ProgressSnapshot{
+		ProgressTotals: SummarizeProgress(p.source()),
+		Tasks:          make([]map[string]any, 0, len(changed)),
+	}
+	for _, task := range changed {
+		snapshot.Tasks = append(snapshot.Tasks, task.ToMap())
+	}
+	p.out.Emit(Event{Type: EventProgressBatch, Data: snapshot})
+}
@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"testing"
+)
+
+func TestProgressAggregatorCoalescesUpdates(t *testing.T) {
+	a := &DownloadTask{URL: "https://example.com/a.bin", TotalBytes: 100, DownloadedBytes: 50, Status: StatusDownloading}
+	b := &DownloadTask{URL: "https://example.com/b.bin", TotalBytes: 300, DownloadedBytes: 150, Status: StatusDownloading}
+
+	var batches []*ProgressSnapshot
+	out := EventSinkFunc(func(ev Event) {
+		if ev.Type == EventProgressBatch {
+			batches = append(batches, ev.Data.(*ProgressSnapshot))
+		}
+	})
+	agg := NewProgressAggregator(out, 0, func() []*DownloadTask { return []*DownloadTask{a, b} })
+
+	for i := 0; i < 10; i++ {
+		agg.Emit(Event{Type: EventProgress, Task: a})
+	}
+	agg.Flush()
+
+	if len(batches) != 1 {
+		t.Fatalf("多次更新应合并为一次推送，实际 %d 次", len(batches))
+	}
+	snapshot := batches[0]
+	if len(snapshot.Tasks) != 1 || snapshot.Tasks[0]["url"] != a.URL {
+		t.Errorf("只应包含有变化的任务: %v", snapshot.Tasks)
+	}
+	if snapshot.Downloaded != 200 || snapshot.Total != 400 || snapshot.Percentage != 50 || snapshot.Active != 2 {
+		t.Errorf("总体进度不正确: %+v", snapshot.ProgressTotals)
+	}
+
+	agg.Flush()
+	if len(batches) != 1 {
+		t.Errorf("没有变化时不应推送")
+	}
+}
@@ -0,0 +1,200 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ProxyMode string
+
+const (
+	ProxySystem ProxyMode = "system" // 使用 HTTP(S)_PROXY / NO_PROXY 环境变量
+	ProxyNone   ProxyMode = "none"   // 直连
+	ProxyManual ProxyMode = "manual" // 使用 URL 指定的代理
+)
+
+// ProxyConfig 代理设置，URL 支持 http://、https://（CONNECT）和 socks5://
+type ProxyConfig struct {
+	Mode     ProxyMode `json:"mode"`
+	URL      string    `json:"url"`
+	Username string    `json:"username"`
+	Password string    `json:"password"` // 保存设置时单独加密存储
+	Bypass   []string  `json:"bypass"`   // 直连的主机，支持 example.com、*.example.com、10.0.0.0/8
+}
+
+func (c ProxyConfig) Validate() error {
+	switch c.Mode {
+	case "", ProxySystem, ProxyNone:
+	case ProxyManual:
+		if _, err := c.parseURL(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持的代理模式: %s", c.Mode)
+	}
+
+	for _, rule := range c.Bypass {
+		rule = strings.TrimSpace(rule)
+		if strings.Contains(rule, "/") {
+			if _, _, err := net.ParseCIDR(rule); err != nil {
+				return fmt.Errorf("代理例外规则无效: %s", rule)
+			}
+		}
+	}
+	return nil
+}
+
+func (c ProxyConfig) parseURL() (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(c.URL))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("代理地址无效: %s", c.URL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+	if c.Username != "" {
+		u.User = url.UserPassword(c.Username, c.Password)
+	}
+	return u, nil
+}
+
+// ProxyFunc 返回 http.Transport 使用的代理选择函数
+// 配置无效时返回的函数会让每个请求都失败，而不是静默直连
+func (c ProxyConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	var next func(*http.Request) (*url.URL, error)
+	switch c.Mode {
+	case ProxyNone:
+		return nil
+	case ProxyManual:
+		proxyURL, err := c.parseURL()
+		if err != nil {
+			return func(*http.Request) (*url.URL, error) { return nil, err }
+		}
+		next = http.ProxyURL(proxyURL)
+	default:
+		next = http.ProxyFromEnvironment
+	}
+
+	bypass := c.Bypass
+	return func(req *http.Request) (*url.URL, error) {
+		if matchBypass(req.URL.Host, bypass) {
+			return nil, nil
+		}
+		return next(req)
+	}
+}
+
+// matchBypass 判断主机是否命中例外规则，规则不含端口时匹配任意端口
+func matchBypass(hostport string, rules []string) bool {
+	host := strings.ToLower(hostWithoutPort(hostport))
+	ip := net.ParseIP(host)
+
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case rule == "":
+			continue
+		case rule == "*":
+			return true
+		case strings.Contains(rule, "/"):
+			if _, cidr, err := net.ParseCIDR(rule); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(rule, "*.") || strings.HasPrefix(rule, "."):
+			suffix := strings.TrimPrefix(rule, "*")
+			if strings.HasSuffix(host, suffix) || host == suffix[1:] {
+				return true
+			}
+		default:
+			if _, _, err := net.SplitHostPort(rule); err == nil {
+				if strings.EqualFold(hostport, rule) {
+					return true
+				}
+				continue
+			}
+			if host == rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactProxy 返回不含密码的代理地址，用于展示
+func redactProxy(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(clean.User.Username())
+	}
+	return clean.String()
+}
+
+// ConnectionTestResult 连接测试结果
+type ConnectionTestResult struct {
+	OK         bool   `json:"ok"`
+	StatusCode int    `json:"statusCode"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Proxy      string `json:"proxy"` // 实际使用的代理，直连时为空
+	Error      string `json:"error"`
+}
+
+// TestConnection 通过引擎当前的连接配置（代理、超时、认证）探测目标地址
+// 预签名地址通常只允许 GET，HEAD 被拒绝时改用只请求首字节的 GET
+func (e *DownloadEngine) TestConnection(ctx context.Context, rawURL string) ConnectionTestResult {
+	var result ConnectionTestResult
+
+	opts := e.Options()
+	if proxyFunc := opts.Proxy.ProxyFunc(); proxyFunc != nil {
+		if req, err := http.NewRequest("GET", rawURL, nil); err == nil {
+			proxyURL, _ := proxyFunc(req)
+			result.Proxy = redactProxy(proxyURL)
+		}
+	}
+
+	start := time.Now()
+	resp, err := e.probe(ctx, "HEAD", rawURL, opts, nil)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusForbidden) {
+		resp.Body.Close()
+		resp, err = e.probe(ctx, "GET", rawURL, opts, nil)
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		// 错误中包含完整的请求地址，去除预签名参数
+		result.Error = RedactText(err.Error())
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.OK = resp.StatusCode < 400
+	if !result.OK {
+		result.Error = (&httpStatusError{Code: resp.StatusCode}).Error()
+	}
+	return result
+}
+
+// probe 发送探测请求，task 不为空时附加任务的请求头和 Cookie
+func (e *DownloadEngine) probe(ctx context.Context, method, rawURL string, opts EngineOptions, task *DownloadTask) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	if task != nil {
+		task.applyRequestHeaders(req)
+	}
+	if method == "GET" {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	return e.client().Do(req)
+}
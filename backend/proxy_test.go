@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchBypass(t *testing.T) {
+	rules := []string{"internal.example.com", "*.corp.local", ".minio.lan", "10.0.0.0/8", "storage:9000"}
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{"internal.example.com", true},
+		{"internal.example.com:443", true},
+		{"other.example.com", false},
+		{"a.corp.local", true},
+		{"corp.local", true},
+		{"minio.lan", true},
+		{"s3.minio.lan:9000", true},
+		{"10.1.2.3:9000", true},
+		{"11.1.2.3", false},
+		{"storage:9000", true},
+		{"storage:9001", false},
+	}
+	for _, c := range cases {
+		if got := matchBypass(c.host, rules); got != c.want {
+			t.Errorf("matchBypass(%q) = %v，期望 %v", c.host, got, c.want)
+		}
+	}
+}
+
+func TestManualProxyFunc(t *testing.T) {
+	config := ProxyConfig{
+		Mode:     ProxyManual,
+		URL:      "socks5://proxy.corp:1080",
+		Username: "user",
+		Password: "pw",
+		Bypass:   []string{"*.internal"},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+
+	proxyFunc := config.ProxyFunc()
+	req, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/a.zip", nil)
+	proxyURL, err := proxyFunc(req)
+	if err != nil || proxyURL == nil {
+		t.Fatalf("期望使用代理，实际: %v, %v", proxyURL, err)
+	}
+	if proxyURL.User.Username() != "user" {
+		t.Errorf("代理地址应携带认证信息: %s", proxyURL)
+	}
+
+	req, _ = http.NewRequest("GET", "https://minio.internal/a.zip", nil)
+	if proxyURL, _ := proxyFunc(req); proxyURL != nil {
+		t.Errorf("例外主机应直连，实际代理: %s", proxyURL)
+	}
+
+	if (ProxyConfig{Mode: ProxyManual, URL: "ftp://proxy"}).Validate() == nil {
+		t.Errorf("不支持的代理协议应校验失败")
+	}
+}
+
+func TestConnectionThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// HTTP 代理收到的是绝对 URI
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	opts := DefaultEngineOptions()
+	opts.Proxy = ProxyConfig{Mode: ProxyManual, URL: proxy.URL, Username: "u", Password: "secret"}
+	engine := NewDownloadEngine(opts)
+
+	result := engine.TestConnection(context.Background(), "http://storage.example.invalid/a.zip")
+	if !result.OK {
+		t.Fatalf("期望连接成功，实际: %+v", result)
+	}
+	if proxied != "http://storage.example.invalid/a.zip" {
+		t.Errorf("请求未经过代理: %q", proxied)
+	}
+	if result.Proxy == "" || strings.Contains(result.Proxy, "secret") {
+		t.Errorf("结果应包含脱敏后的代理地址: %q", result.Proxy)
+	}
+}
+
+func TestConnectionErrorIsRedacted(t *testing.T) {
+	opts := DefaultEngineOptions()
+	opts.Proxy = ProxyConfig{Mode: ProxyNone}
+	engine := NewDownloadEngine(opts)
+
+	result := engine.TestConnection(context.Background(), "http://127.0.0.1:1/a.zip?X-Amz-Signature=secret-sig")
+	if result.OK || result.Error == "" {
+		t.Fatalf("期望连接失败，实际: %+v", result)
+	}
+	if strings.Contains(result.Error, "secret-sig") {
+		t.Errorf("错误信息不应包含签名: %s", result.Error)
+	}
+}
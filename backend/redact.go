@@ -0,0 +1,26 @@
+package backend
+
+import (
+	"net/url"
+	"regexp"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// RedactURL 去掉 URL 中的查询参数和用户信息，用于日志与事件展示
+// 预签名地址的签名都在查询参数里，不能原样输出
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// RedactText 对文本中出现的所有 URL 执行 RedactURL，用于错误信息等自由文本
+func RedactText(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, RedactURL)
+}
@@ -0,0 +1,363 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const selectionFileName = "selection.json"
+
+// 规则匹配的字段
+const (
+	RuleFieldPath = "path" // 脚本中的文件相对路径
+	RuleFieldTask = "task" // 任务名称
+)
+
+// SelectionRule 包含或排除规则
+// Pattern 默认是 glob：不含 / 时匹配文件名，含 / 时匹配完整路径；以 re: 开头时为正则表达式，在字段中查找匹配
+type SelectionRule struct {
+	Field   string `json:"field"` // path 或 task，为空时按 path 处理
+	Pattern string `json:"pattern"`
+}
+
+func (r SelectionRule) field() string {
+	if r.Field == "" {
+		return RuleFieldPath
+	}
+	return r.Field
+}
+
+// TaskSelection 单个任务的勾选状态，只记录取消勾选的项，新加入的任务和文件默认选中
+type TaskSelection struct {
+	Disabled      bool     `json:"disabled,omitempty"`
+	ExcludedFiles []string `json:"excludedFiles,omitempty"` // 取消勾选的文件路径
+}
+
+// Selection 选择性下载的设置
+// 文件被选中需要同时满足：任务和文件都已勾选；有包含规则的字段至少匹配其中一条；不匹配任何排除规则
+type Selection struct {
+	Include []SelectionRule           `json:"include"`
+	Exclude []SelectionRule           `json:"exclude"`
+	Tasks   map[string]*TaskSelection `json:"tasks"` // 按 TaskId 索引
+}
+
+type compiledRule struct {
+	field string
+	glob  string
+	re    *regexp.Regexp
+}
+
+func compileRules(rules []SelectionRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		field := rule.field()
+		if field != RuleFieldPath && field != RuleFieldTask {
+			return nil, fmt.Errorf("不支持的规则字段: %s", rule.Field)
+		}
+		pattern := strings.TrimSpace(rule.Pattern)
+		if pattern == "" {
+			return nil, fmt.Errorf("规则不能为空")
+		}
+		c := compiledRule{field: field}
+		if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("正则表达式 %q 无效: %w", expr, err)
+			}
+			c.re = re
+		} else {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("通配符 %q 无效: %w", pattern, err)
+			}
+			c.glob = pattern
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func (c compiledRule) match(taskName, filePath string) bool {
+	value := filePath
+	if c.field == RuleFieldTask {
+		value = taskName
+	}
+	if c.re != nil {
+		return c.re.MatchString(value)
+	}
+	if c.field == RuleFieldPath && !strings.Contains(c.glob, "/") {
+		value = path.Base(value)
+	}
+	ok, _ := path.Match(c.glob, value)
+	return ok
+}
+
+// selectionFile 选择设置文件的内容，按脚本的绝对路径分别保存，避免规则和勾选状态带到其他脚本
+type selectionFile struct {
+	Scripts map[string]*Selection `json:"scripts"`
+}
+
+// SelectionStore 保存在配置目录的选择设置，所有修改立即写入磁盘
+// 同一时间只有一个脚本的设置生效，加载脚本时通过 Use 切换
+type SelectionStore struct {
+	mu        sync.RWMutex
+	path      string
+	scripts   map[string]*Selection
+	selection *Selection // 当前脚本的设置，指向 scripts 中的元素，未加载脚本时为空路径对应的设置
+	include   []compiledRule
+	exclude   []compiledRule
+}
+
+// OpenSelectionStore 读取 dir 下的选择设置，dir 为空时使用用户配置目录；文件不存在时全部选中
+func OpenSelectionStore(dir string) (*SelectionStore, error) {
+	if dir == "" {
+		var err error
+		if dir, err = AppConfigDir(); err != nil {
+			return nil, err
+		}
+	}
+	s := &SelectionStore{
+		path:    filepath.Join(dir, selectionFileName),
+		scripts: make(map[string]*Selection),
+	}
+	s.selection = s.scriptSelection("")
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取选择设置失败: %w", err)
+	}
+	var file selectionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("选择设置格式错误: %w", err)
+	}
+	for script, selection := range file.Scripts {
+		if selection == nil {
+			continue
+		}
+		if selection.Tasks == nil {
+			selection.Tasks = make(map[string]*TaskSelection)
+		}
+		s.scripts[script] = selection
+	}
+	s.selection = s.scriptSelection("")
+	if err := s.setRules(s.selection.Include, s.selection.Exclude); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Use 切换到 script 对应的选择设置，没有保存过设置的脚本全部选中
+// 保存的规则无效时清除该脚本的规则并返回错误，勾选状态仍然生效
+func (s *SelectionStore) Use(script string) error {
+	key := script
+	if abs, err := filepath.Abs(script); err == nil {
+		key = abs
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.selection = s.scriptSelection(key)
+	if err := s.setRules(s.selection.Include, s.selection.Exclude); err != nil {
+		s.selection.Include, s.selection.Exclude = nil, nil
+		s.include, s.exclude = nil, nil
+		return fmt.Errorf("脚本 %s 的选择规则无效，已清除: %w", filepath.Base(key), err)
+	}
+	return nil
+}
+
+func (s *SelectionStore) scriptSelection(script string) *Selection {
+	selection := s.scripts[script]
+	if selection == nil {
+		selection = &Selection{Tasks: make(map[string]*TaskSelection)}
+		s.scripts[script] = selection
+	}
+	return selection
+}
+
+// Selection 返回当前设置的副本
+func (s *SelectionStore) Selection() Selection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	copied := Selection{
+		Include: append([]SelectionRule{}, s.selection.Include...),
+		Exclude: append([]SelectionRule{}, s.selection.Exclude...),
+		Tasks:   make(map[string]*TaskSelection, len(s.selection.Tasks)),
+	}
+	for id, ts := range s.selection.Tasks {
+		copied.Tasks[id] = &TaskSelection{
+			Disabled:      ts.Disabled,
+			ExcludedFiles: append([]string(nil), ts.ExcludedFiles...),
+		}
+	}
+	return copied
+}
+
+// SetRules 替换包含和排除规则，规则无效时不做任何修改
+func (s *SelectionStore) SetRules(include, exclude []SelectionRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.setRules(include, exclude); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *SelectionStore) setRules(include, exclude []SelectionRule) error {
+	compiledInclude, err := compileRules(include)
+	if err != nil {
+		return fmt.Errorf("包含规则: %w", err)
+	}
+	compiledExclude, err := compileRules(exclude)
+	if err != nil {
+		return fmt.Errorf("排除规则: %w", err)
+	}
+	s.selection.Include = append([]SelectionRule{}, include...)
+	s.selection.Exclude = append([]SelectionRule{}, exclude...)
+	s.include, s.exclude = compiledInclude, compiledExclude
+	return nil
+}
+
+// SetTaskSelected 勾选或取消勾选整个任务
+func (s *SelectionStore) SetTaskSelected(taskId int64, selected bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.taskSelection(taskId).Disabled = !selected
+	s.prune(taskId)
+	return s.save()
+}
+
+// SetFileSelected 勾选或取消勾选任务中的单个文件，filePath 为脚本中的相对路径
+func (s *SelectionStore) SetFileSelected(taskId int64, filePath string, selected bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := s.taskSelection(taskId)
+	excluded := ts.ExcludedFiles[:0]
+	for _, p := range ts.ExcludedFiles {
+		if p != filePath {
+			excluded = append(excluded, p)
+		}
+	}
+	if !selected {
+		excluded = append(excluded, filePath)
+		sort.Strings(excluded)
+	}
+	ts.ExcludedFiles = excluded
+	s.prune(taskId)
+	return s.save()
+}
+
+// Reset 清除当前脚本的所有规则和勾选状态，恢复为全部选中
+func (s *SelectionStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.selection = Selection{Tasks: make(map[string]*TaskSelection)}
+	s.include, s.exclude = nil, nil
+	return s.save()
+}
+
+// Selected 判断文件是否被选中，store 为 nil 时全部选中
+func (s *SelectionStore) Selected(task TaskInfo, file FileInfo) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ts := s.selection.Tasks[strconv.FormatInt(task.TaskId, 10)]; ts != nil {
+		if ts.Disabled {
+			return false
+		}
+		for _, p := range ts.ExcludedFiles {
+			if p == file.Path {
+				return false
+			}
+		}
+	}
+	for _, rule := range s.exclude {
+		if rule.match(task.TaskName, file.Path) {
+			return false
+		}
+	}
+
+	// 同一字段的包含规则之间是“或”，不同字段之间是“且”
+	matched := map[string]bool{}
+	for _, rule := range s.include {
+		if _, ok := matched[rule.field]; !ok {
+			matched[rule.field] = false
+		}
+		if rule.match(task.TaskName, file.Path) {
+			matched[rule.field] = true
+		}
+	}
+	for _, ok := range matched {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// TaskSelected 任务是否被勾选，不考虑规则和单个文件
+func (s *SelectionStore) TaskSelected(taskId int64) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts := s.selection.Tasks[strconv.FormatInt(taskId, 10)]
+	return ts == nil || !ts.Disabled
+}
+
+func (s *SelectionStore) taskSelection(taskId int64) *TaskSelection {
+	id := strconv.FormatInt(taskId, 10)
+	ts := s.selection.Tasks[id]
+	if ts == nil {
+		ts = &TaskSelection{}
+		s.selection.Tasks[id] = ts
+	}
+	return ts
+}
+
+// prune 删除与默认状态相同的记录，避免文件随任务增多无限增长
+func (s *SelectionStore) prune(taskId int64) {
+	id := strconv.FormatInt(taskId, 10)
+	if ts := s.selection.Tasks[id]; ts != nil && !ts.Disabled && len(ts.ExcludedFiles) == 0 {
+		delete(s.selection.Tasks, id)
+	}
+}
+
+// save 先写临时文件再重命名，调用方需持有锁；全部选中的脚本不写入文件
+func (s *SelectionStore) save() error {
+	file := selectionFile{Scripts: make(map[string]*Selection)}
+	for script, selection := range s.scripts {
+		if len(selection.Include) > 0 || len(selection.Exclude) > 0 || len(selection.Tasks) > 0 {
+			file.Scripts[script] = selection
+		}
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("保存选择设置失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存选择设置失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectionStoreRules(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSelectionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	demo := TaskInfo{TaskId: 1, TaskName: "演示任务"}
+	other := TaskInfo{TaskId: 2, TaskName: "其他任务"}
+	zip := FileInfo{Path: "cap_1/data/a.zip"}
+	json := FileInfo{Path: "cap_1/meta.json"}
+
+	if !store.Selected(demo, zip) || !store.Selected(other, json) {
+		t.Fatal("没有设置时应全部选中")
+	}
+
+	if err := store.SetRules(
+		[]SelectionRule{{Pattern: "*.zip"}, {Pattern: "*.json"}, {Field: RuleFieldTask, Pattern: "re:^演示"}},
+		[]SelectionRule{{Pattern: "cap_1/meta.json"}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		task TaskInfo
+		file FileInfo
+		want bool
+	}{
+		{demo, zip, true},
+		{demo, json, false},                          // 被排除
+		{other, zip, false},                          // 任务名不匹配
+		{demo, FileInfo{Path: "cap_1/b.bin"}, false}, // 路径不匹配
+	}
+	for _, c := range cases {
+		if got := store.Selected(c.task, c.file); got != c.want {
+			t.Errorf("Selected(%s, %s) = %v, 期望 %v", c.task.TaskName, c.file.Path, got, c.want)
+		}
+	}
+
+	if err := store.SetRules([]SelectionRule{{Pattern: "re:("}}, nil); err == nil {
+		t.Error("无效的正则应返回错误")
+	}
+	if err := store.SetRules([]SelectionRule{{Field: "url", Pattern: "*"}}, nil); err == nil {
+		t.Error("不支持的字段应返回错误")
+	}
+	if !store.Selected(demo, zip) {
+		t.Error("设置失败时应保留原有规则")
+	}
+}
+
+func TestSelectionStoreCheckboxesPersist(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSelectionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := TaskInfo{TaskId: 2013529099792277505, TaskName: "演示任务"}
+	a := FileInfo{Path: "cap/a.zip"}
+	b := FileInfo{Path: "cap/b.zip"}
+
+	if err := store.SetFileSelected(task.TaskId, b.Path, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRules(nil, []SelectionRule{{Field: RuleFieldTask, Pattern: "其他*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenSelectionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Selected(task, a) || reopened.Selected(task, b) {
+		t.Error("取消勾选的文件应在重新打开后保持")
+	}
+	if len(reopened.Selection().Exclude) != 1 {
+		t.Errorf("规则应持久化: %+v", reopened.Selection())
+	}
+
+	reopened.SetTaskSelected(task.TaskId, false)
+	if reopened.Selected(task, a) || reopened.TaskSelected(task.TaskId) {
+		t.Error("取消勾选任务后其所有文件都不应选中")
+	}
+	reopened.SetTaskSelected(task.TaskId, true)
+	reopened.SetFileSelected(task.TaskId, b.Path, true)
+	if len(reopened.Selection().Tasks) != 0 {
+		t.Errorf("恢复默认状态的任务应从设置中删除: %+v", reopened.Selection().Tasks)
+	}
+
+	if err := reopened.Reset(); err != nil || len(reopened.Selection().Exclude) != 0 {
+		t.Errorf("重置后应清除规则: %v", err)
+	}
+}
+
+func TestSelectionHonouredByVerifyAndPlan(t *testing.T) {
+	root := t.TempDir()
+	writeCaptureFile(t, filepath.Join(root, "cap", "skipped.bin"), 3)
+	store, _ := OpenSelectionStore(t.TempDir())
+	store.SetRules(nil, []SelectionRule{{Pattern: "skipped.*"}})
+
+	tasks := []TaskInfo{{TaskId: 1, Files: []FileInfo{
+		{URL: "http://127.0.0.1:1/a", Path: "cap/a.bin"},
+		{URL: "http://127.0.0.1:1/s", Path: "cap/skipped.bin", Size: 10},
+	}}}
+	report, err := VerifyDirectory(context.Background(), root, tasks, VerifyOptions{Selected: store.Selected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary != (VerifySummary{Missing: 1}) {
+		t.Errorf("未选中的文件不应校验，也不应算作多余: %+v", report.Summary)
+	}
+
+	plan := NewDownloadEngine(DefaultEngineOptions()).BuildPlan(context.Background(), root, tasks, PlanOptions{Selected: store.Selected})
+	if plan.Items[1].Action != PlanSkip || plan.Items[1].Selected || plan.Summary.Download != 1 {
+		t.Errorf("未选中的文件应跳过: %+v", plan.Items)
+	}
+}
+
+func TestSelectionStoreScopedByScript(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSelectionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := TaskInfo{TaskId: 1, TaskName: "演示任务"}
+	file := FileInfo{Path: "cap/a.zip"}
+
+	store.Use(filepath.Join(dir, "first.sh"))
+	store.SetRules(nil, []SelectionRule{{Pattern: "*.zip"}})
+	store.SetTaskSelected(2, false)
+
+	store.Use(filepath.Join(dir, "second.sh"))
+	if !store.Selected(task, file) || !store.TaskSelected(2) {
+		t.Error("其他脚本不应沿用第一个脚本的选择设置")
+	}
+
+	reopened, err := OpenSelectionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.Use(filepath.Join(dir, "first.sh"))
+	if reopened.Selected(task, file) || reopened.TaskSelected(2) {
+		t.Error("重新打开后应恢复第一个脚本的选择设置")
+	}
+	reopened.Reset()
+	reopened.Use(filepath.Join(dir, "second.sh"))
+	if sel := reopened.Selection(); len(sel.Exclude) != 0 || len(sel.Tasks) != 0 {
+		t.Errorf("切换脚本后不应有设置: %+v", sel)
+	}
+}
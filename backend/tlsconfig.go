@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TLSConfig 自定义信任的 CA 与客户端证书（mTLS），用于私有对象存储
+type TLSConfig struct {
+	CAFiles    []string          `json:"caFiles"`    // 额外信任的 CA PEM 文件，追加到系统根证书
+	ClientCert string            `json:"clientCert"` // 客户端证书 PEM 文件
+	ClientKey  string            `json:"clientKey"`  // 客户端私钥 PEM 文件
+	Hosts      []TLSHostOverride `json:"hosts"`      // 按主机覆盖
+}
+
+// TLSHostOverride 针对单个主机的 TLS 设置，在全局设置基础上生效
+type TLSHostOverride struct {
+	Host               string   `json:"host"` // minio.local 匹配任意端口，minio.local:9000 只匹配该端口
+	CAFiles            []string `json:"caFiles"`
+	ClientCert         string   `json:"clientCert"`
+	ClientKey          string   `json:"clientKey"`
+	ServerName         string   `json:"serverName"`
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"`
+}
+
+func (c TLSConfig) isZero() bool {
+	return len(c.CAFiles) == 0 && c.ClientCert == "" && c.ClientKey == "" && len(c.Hosts) == 0
+}
+
+// Validate 实际加载所有证书文件，确保保存设置时就能发现路径或格式错误
+func (c TLSConfig) Validate() error {
+	if _, err := c.baseConfig(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, override := range c.Hosts {
+		host, err := tlsHostKey(override.Host)
+		if err != nil {
+			return err
+		}
+		if seen[host] {
+			return fmt.Errorf("TLS 主机覆盖重复: %s", override.Host)
+		}
+		seen[host] = true
+		if _, err := c.hostConfig(override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c TLSConfig) baseConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if err := addCAFiles(config, c.CAFiles); err != nil {
+		return nil, err
+	}
+	if err := setClientCert(config, c.ClientCert, c.ClientKey); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c TLSConfig) hostConfig(override TLSHostOverride) (*tls.Config, error) {
+	config, err := c.baseConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := addCAFiles(config, override.CAFiles); err != nil {
+		return nil, err
+	}
+	if override.ClientCert != "" || override.ClientKey != "" {
+		config.Certificates = nil
+		if err := setClientCert(config, override.ClientCert, override.ClientKey); err != nil {
+			return nil, err
+		}
+	}
+	config.ServerName = override.ServerName
+	config.InsecureSkipVerify = override.InsecureSkipVerify
+	return config, nil
+}
+
+func addCAFiles(config *tls.Config, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if config.RootCAs == nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		config.RootCAs = pool
+	}
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA 证书文件中没有有效的 PEM 证书: %s", file)
+		}
+	}
+	return nil
+}
+
+func setClientCert(config *tls.Config, certFile, keyFile string) error {
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("客户端证书和私钥必须同时配置")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("加载客户端证书失败: %w", err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return nil
+}
+
+// tlsHostKey 规范化主机覆盖的键：小写，带端口时为 host:port
+func tlsHostKey(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return "", fmt.Errorf("TLS 主机覆盖缺少主机名")
+	}
+	if strings.Contains(host, "/") {
+		return "", fmt.Errorf("TLS 主机覆盖应为主机名或主机名:端口: %s", host)
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || h == "" {
+			return "", fmt.Errorf("TLS 主机覆盖端口无效: %s", host)
+		}
+		return net.JoinHostPort(h, port), nil
+	}
+	return strings.Trim(host, "[]"), nil
+}
+
+// hostRoutingTransport 按请求主机选择不同 TLS 配置的 Transport，带端口的覆盖优先
+type hostRoutingTransport struct {
+	base  *http.Transport
+	hosts map[string]*http.Transport
+}
+
+func (t *hostRoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.route(req.URL).RoundTrip(req)
+}
+
+func (t *hostRoutingTransport) route(u *url.URL) *http.Transport {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if rt, ok := t.hosts[net.JoinHostPort(host, port)]; ok {
+		return rt
+	}
+	if rt, ok := t.hosts[host]; ok {
+		return rt
+	}
+	return t.base
+}
+
+func (t *hostRoutingTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	for _, rt := range t.hosts {
+		rt.CloseIdleConnections()
+	}
+}
+
+// errTransport 配置无效时让每个请求都返回同一个错误，而不是静默降级
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert 生成自签名客户端证书，返回证书、私钥文件路径和证书本身
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "downloader"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile, cert
+}
+
+func TestMutualTLSHostOverride(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+	serverURL, _ := url.Parse(server.URL)
+	opts := DefaultEngineOptions()
+	opts.TLS = TLSConfig{
+		Hosts: []TLSHostOverride{{
+			Host:       serverURL.Hostname(),
+			CAFiles:    []string{caFile},
+			ClientCert: certFile,
+			ClientKey:  keyFile,
+			ServerName: "example.com", // httptest 证书签发给 example.com
+		}},
+	}
+	if err := opts.TLS.Validate(); err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+
+	result := NewDownloadEngine(opts).TestConnection(context.Background(), server.URL+"/a.zip")
+	if !result.OK {
+		t.Fatalf("期望 mTLS 连接成功，实际: %+v", result)
+	}
+
+	// 没有主机覆盖时服务器证书不受信任
+	result = NewDownloadEngine(DefaultEngineOptions()).TestConnection(context.Background(), server.URL+"/a.zip")
+	if result.OK {
+		t.Errorf("未配置 CA 时连接不应成功")
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	if err := (TLSConfig{CAFiles: []string{"/nonexistent/ca.pem"}}).Validate(); err == nil {
+		t.Errorf("CA 文件不存在时应校验失败")
+	}
+	if err := (TLSConfig{ClientCert: "cert.pem"}).Validate(); err == nil {
+		t.Errorf("只配置证书不配置私钥时应校验失败")
+	}
+	if err := (TLSConfig{Hosts: []TLSHostOverride{{Host: ""}}}).Validate(); err == nil {
+		t.Errorf("主机覆盖缺少主机名时应校验失败")
+	}
+	if err := (TLSConfig{Hosts: []TLSHostOverride{{Host: "minio:abc"}}}).Validate(); err == nil {
+		t.Errorf("主机覆盖端口无效时应校验失败")
+	}
+	if err := (TLSConfig{Hosts: []TLSHostOverride{{Host: "MinIO:9000"}, {Host: "minio:9000"}}}).Validate(); err == nil {
+		t.Errorf("重复的主机覆盖应校验失败")
+	}
+}
+
+// 带端口的覆盖只匹配该端口，未写端口时按协议默认端口匹配
+func TestHostRoutingTransportPorts(t *testing.T) {
+	base, withPort, anyPort, https := &http.Transport{}, &http.Transport{}, &http.Transport{}, &http.Transport{}
+	routing := &hostRoutingTransport{base: base, hosts: map[string]*http.Transport{}}
+	for host, rt := range map[string]*http.Transport{"MinIO:9000": withPort, "minio": anyPort, "store:443": https} {
+		key, err := tlsHostKey(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		routing.hosts[key] = rt
+	}
+
+	for rawURL, want := range map[string]*http.Transport{
+		"https://minio:9000/a": withPort,
+		"https://minio:9001/a": anyPort,
+		"https://store/a":      https,
+		"http://store/a":       base,
+		"https://other:9000/a": base,
+	} {
+		u, _ := url.Parse(rawURL)
+		got := routing.route(u)
+		if got != want {
+			t.Errorf("%s 选择了错误的 Transport", rawURL)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VerifyStatus 单个文件的校验结果
+type VerifyStatus string
+
+const (
+	VerifyOK       VerifyStatus = "ok"
+	VerifyMissing  VerifyStatus = "missing"
+	VerifyPartial  VerifyStatus = "partial"  // 比预期小或压缩包不完整，可以续传
+	VerifyMismatch VerifyStatus = "mismatch" // 比预期大或哈希不一致，需要重新下载
+	VerifyExtra    VerifyStatus = "extra"    // 目录中存在但脚本中没有的文件
+)
+
+type VerifyOptions struct {
+	// Deep 为 true 时计算脚本提供了 sha256 的文件哈希，并完整校验没有预期大小的压缩包
+	Deep bool
+	// Selected 为空时校验全部文件；未选中的文件不校验，但也不算多余
+	Selected func(task TaskInfo, file FileInfo) bool
+}
+
+type VerifyItem struct {
+	Status   VerifyStatus `json:"status"`
+	TaskId   string       `json:"taskId,omitempty"`
+	TaskName string       `json:"taskName,omitempty"`
+	Path     string       `json:"path"`     // 相对下载目录，使用 / 分隔
+	Expected int64        `json:"expected"` // 脚本中的大小，未知时为 0
+	Actual   int64        `json:"actual"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+type VerifySummary struct {
+	OK       int `json:"ok"`
+	Missing  int `json:"missing"`
+	Partial  int `json:"partial"`
+	Mismatch int `json:"mismatch"`
+	Extra    int `json:"extra"`
+}
+
+type VerifyReport struct {
+	Root    string        `json:"root"`
+	Summary VerifySummary `json:"summary"`
+	Items   []VerifyItem  `json:"items"` // 不包含校验通过的文件
+}
+
+// Complete 目录与脚本完全一致时返回 true
+func (r *VerifyReport) Complete() bool {
+	s := r.Summary
+	return s.Missing == 0 && s.Partial == 0 && s.Mismatch == 0 && s.Extra == 0
+}
+
+// Paths 返回指定状态的文件路径
+func (r *VerifyReport) Paths(statuses ...VerifyStatus) []string {
+	var paths []string
+	for _, item := range r.Items {
+		for _, status := range statuses {
+			if item.Status == status {
+				paths = append(paths, item.Path)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+func (r *VerifyReport) add(item VerifyItem) {
+	switch item.Status {
+	case VerifyOK:
+		r.Summary.OK++
+		return
+	case VerifyMissing:
+		r.Summary.Missing++
+	case VerifyPartial:
+		r.Summary.Partial++
+	case VerifyMismatch:
+		r.Summary.Mismatch++
+	case VerifyExtra:
+		r.Summary.Extra++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// VerifyDirectory 将 root 目录与脚本中的每个文件比对
+// 自动解压后的目录视为压缩包的一部分：其中的文件不算多余，压缩包已删除但解压目录存在时视为完整
+func VerifyDirectory(ctx context.Context, root string, tasks []TaskInfo, opts VerifyOptions) (*VerifyReport, error) {
+	if !dirExists(root) {
+		return nil, fmt.Errorf("目录不存在: %s", root)
+	}
+
+	report := &VerifyReport{Root: root, Items: []VerifyItem{}}
+	expected := make(map[string]bool)
+	extractDirs := make(map[string]bool)
+	for _, task := range tasks {
+		for _, file := range task.Files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			rel := filepath.Clean(filepath.FromSlash(file.Path))
+			path := filepath.Join(root, rel)
+			// 与 ResolveTargets 一致，超出下载目录的文件不会下载，也不读取目录之外的文件
+			within := isWithin(root, path)
+			if within {
+				expected[rel] = true
+				if IsZipFile(rel) {
+					extractDirs[ExtractDir(rel)] = true
+				}
+			}
+			if opts.Selected != nil && !opts.Selected(task, file) {
+				continue
+			}
+			var item VerifyItem
+			if within {
+				item = verifyFile(ctx, path, file, opts)
+			} else {
+				item = VerifyItem{Status: VerifyMismatch, Expected: file.Size, Reason: "路径超出下载目录"}
+			}
+			item.TaskId = strconv.FormatInt(task.TaskId, 10)
+			item.TaskName = task.TaskName
+			item.Path = filepath.ToSlash(rel)
+			report.add(item)
+		}
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			if extractDirs[rel] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if expected[rel] || strings.HasPrefix(d.Name(), ".write-test-") {
+			return nil
+		}
+		var size int64
+		if info, err := d.Info(); err == nil {
+			size = info.Size()
+		}
+		report.add(VerifyItem{Status: VerifyExtra, Path: filepath.ToSlash(rel), Actual: size})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("扫描下载目录失败: %w", err)
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool { return report.Items[i].Path < report.Items[j].Path })
+	return report, nil
+}
+
+func verifyFile(ctx context.Context, path string, file FileInfo, opts VerifyOptions) VerifyItem {
+	item := VerifyItem{Expected: file.Size}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if IsZipFile(path) && dirExists(ExtractDir(path)) {
+			item.Status = VerifyOK
+			return item
+		}
+		item.Status = VerifyMissing
+		return item
+	}
+	if err != nil {
+		item.Status = VerifyMismatch
+		item.Reason = err.Error()
+		return item
+	}
+	if info.IsDir() {
+		item.Status = VerifyMismatch
+		item.Reason = "目标路径是目录"
+		return item
+	}
+	item.Actual = info.Size()
+
+	switch {
+	case file.Size > 0 && item.Actual < file.Size:
+		item.Status = VerifyPartial
+		return item
+	case file.Size > 0 && item.Actual > file.Size:
+		item.Status = VerifyMismatch
+		item.Reason = "文件比预期大"
+		return item
+	case item.Actual == 0:
+		item.Status = VerifyPartial
+		return item
+	}
+
+	if opts.Deep {
+		if file.SHA256 != "" {
+			hash, err := HashFile(path)
+			if err != nil {
+				item.Status = VerifyMismatch
+				item.Reason = fmt.Sprintf("计算哈希失败: %v", err)
+				return item
+			}
+			if !strings.EqualFold(hash, file.SHA256) {
+				item.Status = VerifyMismatch
+				item.Reason = "SHA-256 不一致"
+				return item
+			}
+		} else if file.Size == 0 && IsZipFile(path) {
+			// 没有预期大小时，只能通过压缩包结构判断是否下载完整
+			if err := ValidateZip(ctx, path); err != nil {
+				item.Status = VerifyPartial
+				item.Reason = err.Error()
+				return item
+			}
+		}
+	}
+	item.Status = VerifyOK
+	return item
+}
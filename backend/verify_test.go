@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeCaptureFile(t, filepath.Join(root, "cap", "ok.bin"), 10)
+	writeCaptureFile(t, filepath.Join(root, "cap", "partial.bin"), 4)
+	writeCaptureFile(t, filepath.Join(root, "cap", "big.bin"), 12)
+	writeCaptureFile(t, filepath.Join(root, "cap", "hash.bin"), 3)
+	writeCaptureFile(t, filepath.Join(root, "cap", "truncated.zip"), 20)
+	writeCaptureFile(t, filepath.Join(root, "cap", "extra.txt"), 1)
+	// 压缩包已解压并删除
+	writeCaptureFile(t, filepath.Join(root, "cap", "done", "inner.json"), 2)
+
+	tasks := []TaskInfo{{
+		TaskId:   7,
+		TaskName: "演示任务",
+		Files: []FileInfo{
+			{Path: "cap/ok.bin", Size: 10},
+			{Path: "cap/partial.bin", Size: 10},
+			{Path: "cap/big.bin", Size: 10},
+			{Path: "cap/hash.bin", SHA256: "0000"},
+			{Path: "cap/truncated.zip"},
+			{Path: "cap/done.zip"},
+			{Path: "cap/missing.bin"},
+		},
+	}}
+
+	report, err := VerifyDirectory(context.Background(), root, tasks, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VerifySummary{OK: 4, Missing: 1, Partial: 1, Mismatch: 1, Extra: 1}
+	if report.Summary != want {
+		t.Fatalf("快速校验结果 = %+v, 期望 %+v\n%+v", report.Summary, want, report.Items)
+	}
+	if got := report.Paths(VerifyMissing, VerifyPartial); len(got) != 2 || got[0] != "cap/missing.bin" || got[1] != "cap/partial.bin" {
+		t.Errorf("待补全的文件不正确: %v", got)
+	}
+	if report.Items[1].Path != "cap/extra.txt" || report.Items[1].TaskId != "" || report.Items[0].TaskId != "7" {
+		t.Errorf("多余文件不应关联任务: %+v", report.Items)
+	}
+
+	deep, err := VerifyDirectory(context.Background(), root, tasks, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = VerifySummary{OK: 2, Missing: 1, Partial: 2, Mismatch: 2, Extra: 1}
+	if deep.Summary != want || deep.Complete() {
+		t.Errorf("完整校验结果 = %+v, 期望 %+v\n%+v", deep.Summary, want, deep.Items)
+	}
+}
+
+func TestVerifyDirectoryComplete(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "cap", "a.zip")
+	os.MkdirAll(filepath.Dir(archive), 0755)
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("a.txt")
+	w.Write([]byte("hello"))
+	zw.Close()
+	f.Close()
+
+	report, err := VerifyDirectory(context.Background(), root, []TaskInfo{{Files: []FileInfo{{Path: "cap/a.zip"}}}}, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete() || report.Summary.OK != 1 {
+		t.Errorf("目录应与脚本一致: %+v", report)
+	}
+}
+
+// TestVerifyDirectoryRejectsOutsideRoot 超出下载目录的路径报告为不一致，不读取目录之外的文件
+func TestVerifyDirectoryRejectsOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "downloads")
+	writeCaptureFile(t, filepath.Join(parent, "secret.bin"), 10)
+	writeCaptureFile(t, filepath.Join(root, "ok.bin"), 10)
+
+	tasks := []TaskInfo{{TaskId: 1, Files: []FileInfo{
+		{Path: "ok.bin", Size: 10},
+		{Path: "../secret.bin", Size: 10},
+	}}}
+	report, err := VerifyDirectory(context.Background(), root, tasks, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (VerifySummary{OK: 1, Mismatch: 1}); report.Summary != want {
+		t.Fatalf("校验结果 = %+v, 期望 %+v", report.Summary, want)
+	}
+	item := report.Items[0]
+	if item.Path != "../secret.bin" || item.Actual != 0 || item.Reason != "路径超出下载目录" {
+		t.Errorf("超出下载目录的文件应直接拒绝: %+v", item)
+	}
+}
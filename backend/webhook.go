@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type WebhookFormat string
+
+const (
+	WebhookGeneric WebhookFormat = "generic" // 直接发送事件 JSON
+	WebhookSlack   WebhookFormat = "slack"   // Slack Incoming Webhook 兼容的 {"text": ...}
+)
+
+const (
+	WebhookTaskCompleted  = "task.completed"
+	WebhookTaskFailed     = "task.failed"
+	WebhookBatchCompleted = "batch.completed"
+)
+
+const (
+	DefaultWebhookRetries = 3
+	webhookTimeout        = 15 * time.Second
+
+	// 签名头：X-Isaac-Signature = "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+	WebhookSignatureHeader = "X-Isaac-Signature"
+	WebhookTimestampHeader = "X-Isaac-Timestamp"
+)
+
+// WebhookConfig 任务和整批结束时推送通知的地址，URL 为空表示不推送
+// Secret 非空时对每个请求签名，接收方可据此校验来源和防重放
+type WebhookConfig struct {
+	URL        string        `json:"url"`
+	Format     WebhookFormat `json:"format"`
+	Secret     string        `json:"secret"`
+	MaxRetries int           `json:"maxRetries"`
+}
+
+func (c WebhookConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+func (c WebhookConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Webhook 地址无效: %s", RedactURL(c.URL))
+	}
+	switch c.Format {
+	case "", WebhookGeneric, WebhookSlack:
+	default:
+		return fmt.Errorf("不支持的 Webhook 格式: %s", c.Format)
+	}
+	if c.MaxRetries < 0 || c.MaxRetries > 10 {
+		return fmt.Errorf("Webhook 重试次数必须在 0 到 10 之间")
+	}
+	return nil
+}
+
+// WebhookEvent 推送的事件内容
+type WebhookEvent struct {
+	Event     string        `json:"event"`
+	Timestamp time.Time     `json:"timestamp"`
+	Task      *TaskSummary  `json:"task,omitempty"`
+	Batch     *BatchSummary `json:"batch,omitempty"`
+}
+
+// TaskWebhookEvent 按任务结果生成 task.completed 或 task.failed 事件
+func TaskWebhookEvent(summary *TaskSummary) WebhookEvent {
+	event := WebhookTaskCompleted
+	if !summary.Succeeded() {
+		event = WebhookTaskFailed
+	}
+	return WebhookEvent{Event: event, Timestamp: time.Now().UTC(), Task: summary}
+}
+
+func BatchWebhookEvent(summary *BatchSummary) WebhookEvent {
+	return WebhookEvent{Event: WebhookBatchCompleted, Timestamp: time.Now().UTC(), Batch: summary}
+}
+
+// WebhookNotifier 发送 Webhook 请求，使用与下载相同的代理和 TLS 设置
+type WebhookNotifier struct {
+	cfg     WebhookConfig
+	client  *http.Client
+	backoff func(attempt int) time.Duration
+}
+
+func NewWebhookNotifier(cfg WebhookConfig, opts EngineOptions) *WebhookNotifier {
+	if cfg.Format == "" {
+		cfg.Format = WebhookGeneric
+	}
+	return &WebhookNotifier{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: newTransport(opts.normalize()),
+			Timeout:   webhookTimeout,
+		},
+		backoff: retryDelay,
+	}
+}
+
+// CloseIdleConnections 关闭空闲连接，替换为新的 WebhookNotifier 后调用
+func (n *WebhookNotifier) CloseIdleConnections() {
+	n.client.CloseIdleConnections()
+}
+
+// Send 推送事件，网络错误、5xx、408 和 429 时按指数退避重试
+func (n *WebhookNotifier) Send(ctx context.Context, event WebhookEvent) error {
+	body, err := n.payload(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil || !isRetryable(err) || attempt >= n.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(n.backoff(attempt)):
+		}
+	}
+}
+
+func (n *WebhookNotifier) payload(event WebhookEvent) ([]byte, error) {
+	if n.cfg.Format == WebhookSlack {
+		return json.Marshal(map[string]string{"text": slackText(event)})
+	}
+	return json.Marshal(event)
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(n.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Slack 等地址的路径本身就是凭据，错误中只保留协议和主机
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = req.URL.Scheme + "://" + req.URL.Host
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// SignWebhook 计算签名头的值，接收方用同样的方式计算后做常量时间比较
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func slackText(event WebhookEvent) string {
+	switch {
+	case event.Task != nil && event.Event == WebhookTaskFailed:
+		return fmt.Sprintf(":x: 任务 %s（%d）下载失败：%d/%d 个文件失败",
+			event.Task.TaskName, event.Task.TaskId, event.Task.Failed, event.Task.Files)
+	case event.Task != nil:
+		return fmt.Sprintf(":white_check_mark: 任务 %s（%d）下载完成：%d 个文件，%s",
+			event.Task.TaskName, event.Task.TaskId, event.Task.Files, FormatBytes(event.Task.Bytes))
+	case event.Batch != nil:
+		return fmt.Sprintf(":package: 整批下载结束：%d 个任务，%d 个文件，失败 %d 个，共 %s，耗时 %s",
+			event.Batch.Tasks, event.Batch.Files, event.Batch.Failed,
+			FormatBytes(event.Batch.Bytes), event.Batch.Duration.Round(time.Second))
+	default:
+		return event.Event
+	}
+}
@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookSignsPayloadAndRetries(t *testing.T) {
+	const secret = "shared-secret"
+	var calls int32
+	var received WebhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		timestamp := r.Header.Get(WebhookTimestampHeader)
+		if got, want := r.Header.Get(WebhookSignatureHeader), SignWebhook(secret, timestamp, body); got != want {
+			t.Errorf("签名不匹配: %s != %s", got, want)
+		}
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: secret, MaxRetries: 2}, DefaultEngineOptions())
+	notifier.backoff = func(int) time.Duration { return 10 * time.Millisecond }
+
+	summary := &TaskSummary{TaskId: 9, TaskName: "capture", Files: 2, Failed: 1}
+	if err := notifier.Send(context.Background(), TaskWebhookEvent(summary)); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("5xx 后应重试一次，实际请求 %d 次", calls)
+	}
+	if received.Event != WebhookTaskFailed || received.Task == nil || received.Task.TaskId != 9 {
+		t.Errorf("事件内容不正确: %+v", received)
+	}
+}
+
+func TestWebhookSlackFormatAndClientError(t *testing.T) {
+	var calls int32
+	var text string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		text = payload["text"]
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, Format: WebhookSlack, MaxRetries: 3}, DefaultEngineOptions())
+	notifier.backoff = func(int) time.Duration { return 10 * time.Millisecond }
+
+	err := notifier.Send(context.Background(), BatchWebhookEvent(&BatchSummary{Tasks: 2, Files: 5}))
+	if err == nil {
+		t.Fatalf("4xx 应返回错误")
+	}
+	if calls != 1 {
+		t.Errorf("4xx 不应重试，实际请求 %d 次", calls)
+	}
+	if !strings.Contains(text, "2 个任务") {
+		t.Errorf("Slack 消息内容不正确: %q", text)
+	}
+}
+
+// 网络错误中不应出现 Webhook 地址的路径，Slack 等服务的路径就是凭据
+func TestWebhookErrorOmitsURLPath(t *testing.T) {
+	notifier := NewWebhookNotifier(WebhookConfig{URL: "http://127.0.0.1:1/services/T000/B000/secret-path"}, DefaultEngineOptions())
+	err := notifier.Send(context.Background(), BatchWebhookEvent(&BatchSummary{}))
+	if err == nil {
+		t.Fatalf("期望推送失败")
+	}
+	if strings.Contains(err.Error(), "secret-path") || !strings.Contains(err.Error(), "127.0.0.1:1") {
+		t.Errorf("错误中应只保留主机: %v", err)
+	}
+}
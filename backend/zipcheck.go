@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ZipValidationError 压缩包结构校验失败，Reason 可直接展示给用户
+type ZipValidationError struct {
+	Reason string
+}
+
+func (e *ZipValidationError) Error() string {
+	return "压缩包已损坏: " + e.Reason
+}
+
+// ValidateZip 检查压缩包的中央目录结尾记录，并完整读取每个条目以校验 CRC
+// 截断的文件通常在打开时就因找不到中央目录而失败
+func ValidateZip(ctx context.Context, path string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		if errors.Is(err, zip.ErrFormat) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return &ZipValidationError{Reason: "未找到中央目录结尾记录，文件可能未下载完整"}
+		}
+		return fmt.Errorf("打开压缩包失败: %w", err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) == 0 {
+		return &ZipValidationError{Reason: "压缩包中没有任何条目"}
+	}
+
+	for _, f := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := validateEntry(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateEntry(ctx context.Context, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		if errors.Is(err, zip.ErrAlgorithm) {
+			return &ZipValidationError{Reason: fmt.Sprintf("条目 %s 使用了不支持的压缩算法", f.Name)}
+		}
+		return &ZipValidationError{Reason: fmt.Sprintf("条目 %s 无法读取: %v", f.Name, err)}
+	}
+	defer rc.Close()
+
+	// archive/zip 在读到条目末尾时校验 CRC32 和长度
+	_, err = io.Copy(io.Discard, &extractReader{ctx: ctx, r: rc, report: func(int64, bool) {}})
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, zip.ErrChecksum):
+		return &ZipValidationError{Reason: fmt.Sprintf("条目 %s CRC 校验失败", f.Name)}
+	default:
+		return &ZipValidationError{Reason: fmt.Sprintf("条目 %s 数据不完整: %v", f.Name, err)}
+	}
+}
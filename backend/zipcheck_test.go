@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateZip(t *testing.T) {
+	dir := t.TempDir()
+	data := buildZip(t, map[string]string{"meta.txt": strings.Repeat("capture", 100)})
+
+	good := filepath.Join(dir, "good.zip")
+	os.WriteFile(good, data, 0644)
+	if err := ValidateZip(context.Background(), good); err != nil {
+		t.Errorf("完整压缩包应通过校验: %v", err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.zip")
+	os.WriteFile(truncated, data[:len(data)/2], 0644)
+	var invalid *ZipValidationError
+	if err := ValidateZip(context.Background(), truncated); !errors.As(err, &invalid) {
+		t.Errorf("截断的压缩包应返回 ZipValidationError，实际: %v", err)
+	}
+
+	// 修改存储数据中的一个字节，中央目录完好但 CRC 不匹配
+	corrupted := bytes.Replace(data, []byte("capture"), []byte("CAPTURE"), 1)
+	crc := filepath.Join(dir, "crc.zip")
+	os.WriteFile(crc, corrupted, 0644)
+	err := ValidateZip(context.Background(), crc)
+	if !errors.As(err, &invalid) {
+		t.Fatalf("数据损坏的压缩包应返回 ZipValidationError，实际: %v", err)
+	}
+	if !strings.Contains(invalid.Reason, "meta.txt") {
+		t.Errorf("原因应指出损坏的条目: %s", invalid.Reason)
+	}
+}
+
+func TestCorruptZipIsRedownloaded(t *testing.T) {
+	data := buildZip(t, map[string]string{"meta.txt": "capture"})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Write(data[:len(data)-10])
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	opts := DefaultEngineOptions()
+	opts.RedownloadCorrupt = true
+	engine := NewDownloadEngine(opts)
+
+	task := &DownloadTask{URL: server.URL + "/f.zip", LocalPath: filepath.Join(t.TempDir(), "f.zip")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCompleted {
+		t.Fatalf("期望重新下载后完成，实际: %s (%s)", status, task.CorruptReason)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("期望请求 2 次，实际: %d", got)
+	}
+}
+
+func TestCorruptZipMarkedWithoutRedownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a zip"))
+	}))
+	defer server.Close()
+
+	engine := NewDownloadEngine(DefaultEngineOptions())
+	task := &DownloadTask{URL: server.URL + "/g.zip", LocalPath: filepath.Join(t.TempDir(), "g.zip")}
+	engine.StartDownload(task)
+
+	if status := waitForStatus(t, task, 5*time.Second); status != StatusCorrupt {
+		t.Fatalf("期望标记为损坏，实际: %s", status)
+	}
+	if task.CorruptReason == "" {
+		t.Errorf("应记录损坏原因")
+	}
+}
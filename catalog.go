@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"isaac-downloader/backend"
+)
+
+// openCatalog 打开数据集索引，失败时只记录日志
+func (a *App) openCatalog() {
+	catalog, err := backend.OpenCatalog("")
+	if err != nil {
+		a.emitLog("error", fmt.Sprintf("打开数据集索引失败: %v", err))
+		return
+	}
+	a.catalog = catalog
+}
+
+// ScanCatalog 扫描当前下载目录并更新数据集索引，返回新增、移动和缺失的抓取目录
+func (a *App) ScanCatalog() (*backend.CatalogScanResult, error) {
+	if a.catalog == nil {
+		return nil, fmt.Errorf("数据集索引不可用")
+	}
+	result, err := a.catalog.Scan(a.currentSettings().DownloadPath)
+	if err != nil {
+		return nil, err
+	}
+	a.logCatalogScan(result)
+	return result, nil
+}
+
+// requestCatalogScan 提交后台扫描，已有待执行的扫描时不再重复提交，不阻塞调用方
+func (a *App) requestCatalogScan(root string) {
+	if a.catalog == nil || a.catalogScans == nil {
+		return
+	}
+	select {
+	case a.catalogScans <- root:
+	default:
+	}
+}
+
+// scanCatalog 后台扫描 root 并更新索引，失败时只记录日志
+func (a *App) scanCatalog(root string) {
+	result, err := a.catalog.Scan(root)
+	if err != nil {
+		a.emitLog("error", fmt.Sprintf("更新数据集索引失败: %v", err))
+		return
+	}
+	a.logCatalogScan(result)
+}
+
+// logCatalogScan 记录需要用户关注的扫描结果
+func (a *App) logCatalogScan(result *backend.CatalogScanResult) {
+	if len(result.Moved) > 0 || len(result.Missing) > 0 {
+		a.emitLog("warn", fmt.Sprintf("数据集索引：%d 个抓取目录已移动，%d 个已缺失", len(result.Moved), len(result.Missing)))
+	}
+	for _, dir := range result.Duplicates {
+		a.emitLog("warn", fmt.Sprintf("数据集索引：%s 与已索引的抓取目录任务 ID 和抓取时间相同，未加入索引", dir))
+	}
+}
+
+// GetCatalog 按条件查询已索引的抓取目录，按抓取时间倒序
+func (a *App) GetCatalog(query backend.CatalogQuery) []backend.CatalogEntry {
+	if a.catalog == nil {
+		return []backend.CatalogEntry{}
+	}
+	return a.catalog.Query(query)
+}
+
+// RemoveMissingCaptures 从索引中删除已缺失的抓取目录，返回删除的数量
+func (a *App) RemoveMissingCaptures() (int, error) {
+	if a.catalog == nil {
+		return 0, nil
+	}
+	return a.catalog.RemoveMissing()
+}
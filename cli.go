@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+// 命令行退出码
+const (
+	exitOK       = 0
+	exitMismatch = 1 // 校验发现差异，或补全下载后仍有文件失败
+	exitError    = 2
+)
+
+// runCLI 处理命令行子命令，handled 为 false 时按图形界面启动
+func runCLI(args []string, stdout, stderr io.Writer) (code int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "verify":
+		return runVerify(args[1:], stdout, stderr), true
+	case "serve":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return runServe(ctx, args[1:], stdout, stderr), true
+	default:
+		return 0, false
+	}
+}
+
+// newHeadlessApp 使用已保存的设置和登录状态创建不带界面的 App
+// 启用了指标接口时同样对外提供 /metrics，调用方结束时需调用 stopMetrics
+func newHeadlessApp(stderr io.Writer) *App {
+	a := NewApp()
+	a.loadPersistedSettings()
+	a.initAuth()
+	a.rebuildEngine()
+	a.openSelection()
+	if err := a.startMetrics(); err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+	return a
+}
+
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", "", "下载目录，默认使用已保存设置中的下载路径")
+	deep := fs.Bool("deep", false, "计算哈希并完整校验压缩包")
+	download := fs.Bool("download", false, "下载缺失和不完整的文件，完成后再次校验")
+	asJSON := fs.Bool("json", false, "以 JSON 输出校验报告")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: isaac-downloader verify [选项] <脚本路径>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	a := newHeadlessApp(stderr)
+	defer a.stopMetrics()
+	if *dir != "" {
+		settings := *a.currentSettings()
+		settings.DownloadPath = *dir
+		a.setSettings(&settings)
+	}
+	if _, err := a.LoadScript(fs.Arg(0)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *download {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		a.engine.Events().Subscribe(backend.NewTextSink(stderr))
+		started, err := a.DownloadMissing()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "开始下载 %d 个文件\n", started)
+		if !waitForDownloads(ctx, a.engine) {
+			a.engine.PauseAll()
+			fmt.Fprintln(stderr, "已中断，未完成的文件下次可以续传")
+			return exitError
+		}
+	}
+
+	report, err := a.VerifyDownloads(*deep)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		writeVerifyText(stdout, report)
+	}
+	if !report.Complete() {
+		return exitMismatch
+	}
+	return exitOK
+}
+
+// runServe 不带界面常驻运行，对外提供 /metrics 和已启用的控制接口，ctx 取消时退出
+// 给出脚本时加载并开始下载全部已勾选的文件，下载结束后继续运行，供 Prometheus 抓取和控制接口继续提交
+func runServe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", "", "下载目录，默认使用已保存设置中的下载路径")
+	metricsAddr := fs.String("metrics", "", "指标监听地址，默认使用已保存设置中的地址")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: isaac-downloader serve [选项] [脚本路径...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	a := NewApp()
+	a.loadPersistedSettings()
+	// 常驻模式总是提供 /metrics，命令行参数只对本次运行生效，不写入设置文件
+	settings := *a.currentSettings()
+	settings.Metrics.Enabled = true
+	if *metricsAddr != "" {
+		settings.Metrics.Addr = *metricsAddr
+	}
+	if *dir != "" {
+		settings.DownloadPath = *dir
+	}
+	if err := settings.Metrics.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	a.setSettings(&settings)
+
+	go a.progress.Run(ctx)
+	a.initAuth()
+	a.rebuildEngine()
+	a.openHistory()
+	a.openCatalog()
+	a.openSelection()
+	a.startHookWorker()
+	a.engine.Events().Subscribe(backend.NewTextSink(stderr))
+
+	if err := a.startMetrics(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer a.stopMetrics()
+	fmt.Fprintf(stdout, "指标接口: http://%s/metrics\n", settings.Metrics.Addr)
+	if settings.API.Enabled {
+		a.ensureAPIToken()
+		if err := a.startAPI(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		defer a.stopAPI()
+		fmt.Fprintf(stdout, "控制接口: http://127.0.0.1:%d/api/v1\n", settings.API.Port)
+	}
+
+	if fs.NArg() > 0 {
+		for i, script := range fs.Args() {
+			load := a.LoadScriptMerge
+			if i == 0 {
+				load = a.LoadScript
+			}
+			if _, err := load(script); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+		}
+		started, err := a.StartAll()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "开始下载 %d 个文件\n", started)
+	}
+
+	<-ctx.Done()
+	a.engine.PauseAll()
+	fmt.Fprintln(stderr, "已退出，未完成的文件下次可以续传")
+	return exitOK
+}
+
+// waitForDownloads 等待引擎中所有任务结束，ctx 取消时返回 false
+func waitForDownloads(ctx context.Context, engine *backend.DownloadEngine) bool {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		active := false
+		for _, task := range engine.GetRunningTasks() {
+			switch task.GetStatus() {
+			case backend.StatusPending, backend.StatusDownloading, backend.StatusExtracting:
+				active = true
+			}
+		}
+		if !active {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeVerifyText(w io.Writer, report *backend.VerifyReport) {
+	for _, item := range report.Items {
+		line := fmt.Sprintf("%-8s %s", item.Status, item.Path)
+		if item.Expected > 0 {
+			line += fmt.Sprintf(" (%s / %s)", backend.FormatBytes(item.Actual), backend.FormatBytes(item.Expected))
+		} else if item.Actual > 0 {
+			line += fmt.Sprintf(" (%s)", backend.FormatBytes(item.Actual))
+		}
+		if item.Reason != "" {
+			line += ": " + item.Reason
+		}
+		fmt.Fprintln(w, line)
+	}
+	s := report.Summary
+	fmt.Fprintf(w, "%s: 完整 %d，缺失 %d，不完整 %d，不一致 %d，多余 %d\n",
+		report.Root, s.OK, s.Missing, s.Partial, s.Mismatch, s.Extra)
+}
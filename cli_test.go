@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+func TestVerifyCommandDownloadsMissing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	script := filepath.Join(t.TempDir(), "task.sh")
+	content := `FILES_JSON='{"tasks":[{"taskId":1,"files":[` +
+		`{"url":"` + server.URL + `/a.bin","path":"任务_1_20260202_135655/a.bin","size":10},` +
+		`{"url":"` + server.URL + `/b.bin","path":"任务_1_20260202_135655/b.bin","size":10}]}]}'`
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "任务_1_20260202_135655"), 0755)
+	os.WriteFile(filepath.Join(dir, "任务_1_20260202_135655", "a.bin"), []byte("0123"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	code, handled := runCLI([]string{"verify", "-dir", dir, script}, &stdout, &stderr)
+	if !handled || code != exitMismatch {
+		t.Fatalf("缺失文件时退出码应为 %d，实际 %d: %s", exitMismatch, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "partial") || !strings.Contains(stdout.String(), "missing") {
+		t.Errorf("报告应列出不完整和缺失的文件: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	code, _ = runCLI([]string{"verify", "-dir", dir, "-download", "-json", script}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("补全后退出码应为 0，实际 %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	var report backend.VerifyReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil || report.Summary.OK != 2 {
+		t.Errorf("JSON 报告不正确: %v %s", err, stdout.String())
+	}
+
+	if _, handled := runCLI(nil, &stdout, &stderr); handled {
+		t.Error("没有子命令时应启动图形界面")
+	}
+}
+
+func TestHeadlessAppServesMetrics(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	settings := defaultSettings()
+	settings.DownloadPath = t.TempDir()
+	settings.Metrics = MetricsSettings{Enabled: true, Addr: addr}
+	path, err := settingsFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	a := newHeadlessApp(&stderr)
+	defer a.stopMetrics()
+	if stderr.Len() > 0 {
+		t.Fatalf("启动指标接口失败: %s", stderr.String())
+	}
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("无界面模式下 /metrics 状态码 = %d", resp.StatusCode)
+	}
+}
+
+// TestVerifyDownloadResumesRegardlessOfPolicy 补全时按续传处理已有文件，不会另存为新文件
+func TestVerifyDownloadResumesRegardlessOfPolicy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "a.bin", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	settings := defaultSettings()
+	settings.ConflictPolicy = backend.ConflictRename
+	path, err := settingsFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(t.TempDir(), "task.sh")
+	content := `FILES_JSON='{"tasks":[{"taskId":1,"files":[` +
+		`{"url":"` + server.URL + `/a.bin","path":"cap/a.bin","size":10}]}]}'`
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "cap"), 0755)
+	os.WriteFile(filepath.Join(dir, "cap", "a.bin"), []byte("0123"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code, _ := runCLI([]string{"verify", "-dir", dir, "-download", script}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("补全后退出码应为 0，实际 %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "cap", "a.bin")); string(data) != "0123456789" {
+		t.Errorf("应在原文件上续传: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cap", "a (1).bin")); !os.IsNotExist(err) {
+		t.Errorf("补全时不应另存为新文件: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=4-" {
+		t.Errorf("应从已有大小续传，实际请求: %q", ranges)
+	}
+}
+
+// TestServeCommand 常驻模式下载脚本中的文件，下载结束后继续提供 /metrics，取消后退出
+func TestServeCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	dir := t.TempDir()
+	script := filepath.Join(t.TempDir(), "task.sh")
+	content := `FILES_JSON='{"tasks":[{"taskId":1,"files":[{"url":"` + server.URL + `/a.bin","path":"cap/a.bin","size":10}]}]}'`
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 下载事件和 runServe 的提示会同时写入 stderr，os.Stderr 可以并发写，测试中的缓冲区需要加锁
+	var stdout bytes.Buffer
+	var stderr lockedBuffer
+	done := make(chan int, 1)
+	go func() {
+		done <- runServe(ctx, []string{"-dir", dir, "-metrics", addr, script}, &stdout, &stderr)
+	}()
+
+	var body string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get("http://" + addr + "/metrics"); err == nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body = string(data)
+			if strings.Contains(body, `isaac_downloader_files_total{result="completed"} 1`) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !strings.Contains(body, `isaac_downloader_files_total{result="completed"} 1`) {
+		t.Fatalf("下载结束后 /metrics 应统计完成的文件:\n%s", body)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "cap", "a.bin")); string(data) != "0123456789" {
+		t.Errorf("文件内容不正确: %q", data)
+	}
+
+	cancel()
+	select {
+	case code := <-done:
+		if code != exitOK {
+			t.Errorf("退出码 = %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后应退出")
+	}
+	if _, err := http.Get("http://" + addr + "/metrics"); err == nil {
+		t.Error("退出后不应继续监听")
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
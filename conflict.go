@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+// conflictAskTimeout 等待用户选择的上限，超时后按续传处理，避免任务一直占用下载槽位
+const conflictAskTimeout = 10 * time.Minute
+
+// conflictPrompts 等待前端回复的冲突询问
+type conflictPrompts struct {
+	mu      sync.Mutex
+	nextId  atomic.Int64
+	pending map[string]chan backend.ConflictPolicy
+}
+
+func (p *conflictPrompts) add() (string, chan backend.ConflictPolicy) {
+	id := strconv.FormatInt(p.nextId.Add(1), 10)
+	ch := make(chan backend.ConflictPolicy, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = make(map[string]chan backend.ConflictPolicy)
+	}
+	p.pending[id] = ch
+	return id, ch
+}
+
+func (p *conflictPrompts) remove(id string) chan backend.ConflictPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ch := p.pending[id]
+	delete(p.pending, id)
+	return ch
+}
+
+// askConflict 实现 backend.ConflictResolver：发出 conflict 事件并等待 ResolveConflict 回复
+// 没有界面时（命令行）直接续传
+func (a *App) askConflict(ctx context.Context, info backend.ConflictInfo) backend.ConflictPolicy {
+	if a.ctx == nil {
+		return backend.ConflictResume
+	}
+	id, answer := a.conflicts.add()
+	defer a.conflicts.remove(id)
+
+	a.emit(backend.EventConflict, map[string]any{
+		"id":         id,
+		"taskId":     info.TaskId,
+		"taskName":   info.TaskName,
+		"path":       info.Path,
+		"url":        info.URL,
+		"localSize":  info.LocalSize,
+		"remoteSize": info.RemoteSize,
+	})
+
+	timer := time.NewTimer(conflictAskTimeout)
+	defer timer.Stop()
+	select {
+	case policy := <-answer:
+		return policy
+	case <-timer.C:
+		a.emitLog("warn", fmt.Sprintf("%s 等待选择超时，按续传处理", info.Path))
+		return backend.ConflictResume
+	case <-ctx.Done():
+		return backend.ConflictResume
+	}
+}
+
+// ResolveConflict 回复 conflict 事件，policy 为 resume、skip-same-size、overwrite 或 rename
+func (a *App) ResolveConflict(id, policy string) error {
+	p := backend.ConflictPolicy(policy)
+	if err := p.Validate(); err != nil || p == backend.ConflictAsk {
+		return fmt.Errorf("不支持的处理方式: %s", policy)
+	}
+	ch := a.conflicts.remove(id)
+	if ch == nil {
+		return fmt.Errorf("询问已结束或不存在: %s", id)
+	}
+	ch <- p
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+// TestResolveConflict 验证回复只送达对应的询问一次，且不接受 ask 作为回答
+func TestResolveConflict(t *testing.T) {
+	a := &App{}
+	id, answer := a.conflicts.add()
+
+	if err := a.ResolveConflict(id, "ask"); err == nil {
+		t.Errorf("ask 不能作为回答")
+	}
+	if err := a.ResolveConflict(id, "rename"); err != nil {
+		t.Fatalf("回复失败: %v", err)
+	}
+	if got := <-answer; got != backend.ConflictRename {
+		t.Errorf("收到 %s，期望 rename", got)
+	}
+	if err := a.ResolveConflict(id, "rename"); err == nil {
+		t.Errorf("重复回复应返回错误")
+	}
+}
+
+// TestAskConflictHeadless 没有界面时不等待，直接续传
+func TestAskConflictHeadless(t *testing.T) {
+	a := &App{}
+	if got := a.askConflict(context.Background(), backend.ConflictInfo{Path: "a.bin"}); got != backend.ConflictResume {
+		t.Errorf("期望 resume，实际 %s", got)
+	}
+}
+
+// TestAskPolicyAtStartupEmitsConflict 启动时已设置 ask 策略，引擎应询问前端而不是直接续传
+func TestAskPolicyAtStartupEmitsConflict(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "a.bin", time.Time{}, strings.NewReader("hello world"))
+	}))
+	defer server.Close()
+
+	settings := defaultSettings()
+	settings.DownloadPath = t.TempDir()
+	settings.ConflictPolicy = backend.ConflictAsk
+	path, err := settingsFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewApp()
+	a.ctx = context.Background() // 模拟有界面的启动
+	a.loadPersistedSettings()
+	a.initAuth()
+	a.rebuildEngine()
+
+	conflicts := make(chan map[string]any, 1)
+	a.bus.Subscribe(backend.EventSinkFunc(func(ev backend.Event) {
+		if ev.Type == backend.EventConflict {
+			conflicts <- ev.Data.(map[string]any)
+		}
+	}))
+
+	target := filepath.Join(settings.DownloadPath, "a.bin")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task := &backend.DownloadTask{URL: server.URL + "/a.bin", LocalPath: target}
+	a.engine.StartDownload(task)
+
+	select {
+	case data := <-conflicts:
+		if err := a.ResolveConflict(data["id"].(string), "overwrite"); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ask 策略下应发出 conflict 事件")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for task.GetStatus() != backend.StatusCompleted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "hello world" {
+		t.Errorf("选择覆盖后内容不正确: %q", data)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"isaac-downloader/backend"
+)
+
+// appVersion 与 wails.json 中的 productVersion 保持一致
+const appVersion = "1.0.0"
+
+const (
+	redactedValue = "***"
+
+	// diagnosticsProbeTimeout 连通性探测的上限，避免网络异常时导出长时间无响应
+	diagnosticsProbeTimeout = 20 * time.Second
+
+	// diagnosticsJournalSize 诊断包中包含的最近下载记录数
+	diagnosticsJournalSize = 500
+)
+
+// ExportDiagnostics 将设置、任务状态、日志、运行环境和连通性探测结果打包为 ZIP，返回保存路径
+// 所有凭据和预签名地址的查询参数都已去除，可以直接发给技术支持；用户取消时返回空字符串
+func (a *App) ExportDiagnostics() (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出诊断信息",
+		DefaultFilename: fmt.Sprintf("isaac-downloader-diagnostics-%s.zip", time.Now().Format("20060102-150405")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "ZIP 压缩包 (*.zip)", Pattern: "*.zip"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := a.writeDiagnostics(a.ctx, path); err != nil {
+		return "", err
+	}
+	backend.Logger().Info("已导出诊断信息", "path", path)
+	return path, nil
+}
+
+func (a *App) writeDiagnostics(ctx context.Context, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建诊断文件失败: %w", err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	entries := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"environment.json", jsonEntry(a.diagnosticEnvironment())},
+		{"settings.json", jsonEntry(redactSettings(a.currentSettings()))},
+		// 下载状态没有单独持久化，这里导出引擎中所有任务的当前状态
+		{"tasks.json", jsonEntry(a.diagnosticTasks())},
+		{"connectivity.json", jsonEntry(a.diagnosticProbe(ctx))},
+		// 最近的下载记录，只包含主机名，不含签名地址
+		{"journal.json", jsonEntry(a.diagnosticJournal())},
+		{"logs/recent.log", func(w io.Writer) error {
+			return backend.WriteLogText(w, a.GetLogHistory("", "", 0))
+		}},
+	}
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return err
+		}
+		if err := entry.write(w); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", entry.name, err)
+		}
+	}
+	if err := addLogFiles(zw); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("写入诊断文件失败: %w", err)
+	}
+	return file.Close()
+}
+
+func jsonEntry(v any) func(io.Writer) error {
+	return func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+}
+
+// redactSettings 返回去掉账号、密码、Token、签名密钥和钩子命令的设置副本
+// 钩子命令常常带有 Token 等参数，只保留是否配置
+func redactSettings(s *Settings) Settings {
+	copied := *s
+	if copied.Proxy.Username != "" {
+		copied.Proxy.Username = redactedValue
+	}
+	if copied.Proxy.Password != "" {
+		copied.Proxy.Password = redactedValue
+	}
+	if copied.Proxy.URL != "" {
+		copied.Proxy.URL = backend.RedactURL(copied.Proxy.URL)
+	}
+	if copied.API.Token != "" {
+		copied.API.Token = redactedValue
+	}
+	if copied.Webhook.Secret != "" {
+		copied.Webhook.Secret = redactedValue
+	}
+	if copied.Webhook.URL != "" {
+		// Slack 等 Webhook 地址的路径本身就是凭据
+		copied.Webhook.URL = redactedValue
+	}
+	for _, command := range []*string{&copied.Hooks.OnFileComplete, &copied.Hooks.OnTaskComplete, &copied.Hooks.OnBatchComplete} {
+		if *command != "" {
+			*command = redactedValue
+		}
+	}
+	return copied
+}
+
+// restoreRedacted 将仍是脱敏占位的字段恢复为当前值，客户端可以把 GET 的结果修改后原样提交
+func restoreRedacted(next, current *Settings) {
+	redacted := redactSettings(current)
+	if next.Proxy.Username == redacted.Proxy.Username {
+		next.Proxy.Username = current.Proxy.Username
+	}
+	if next.Proxy.Password == redacted.Proxy.Password {
+		next.Proxy.Password = current.Proxy.Password
+	}
+	if next.Proxy.URL == redacted.Proxy.URL {
+		next.Proxy.URL = current.Proxy.URL
+	}
+	if next.API.Token == redacted.API.Token {
+		next.API.Token = current.API.Token
+	}
+	if next.Webhook.Secret == redacted.Webhook.Secret {
+		next.Webhook.Secret = current.Webhook.Secret
+	}
+	if next.Webhook.URL == redacted.Webhook.URL {
+		next.Webhook.URL = current.Webhook.URL
+	}
+}
+
+// diagnosticJournal 返回最近的下载历史，历史未打开时为空
+func (a *App) diagnosticJournal() []backend.HistoryRecord {
+	if a.history == nil {
+		return []backend.HistoryRecord{}
+	}
+	records, err := a.history.Query(backend.HistoryQuery{Limit: diagnosticsJournalSize})
+	if err != nil {
+		return []backend.HistoryRecord{}
+	}
+	return records
+}
+
+func (a *App) diagnosticEnvironment() map[string]any {
+	env := map[string]any{
+		"appVersion":   appVersion,
+		"goVersion":    goruntime.Version(),
+		"os":           goruntime.GOOS,
+		"arch":         goruntime.GOARCH,
+		"numCPU":       goruntime.NumCPU(),
+		"generatedAt":  time.Now().Format(time.RFC3339),
+		"settingsPath": a.settingsPath,
+	}
+	if a.ctx != nil {
+		info := runtime.Environment(a.ctx)
+		env["platform"] = info.Platform
+		env["buildType"] = info.BuildType
+	}
+	if dir, err := backend.AppLogDir(); err == nil {
+		env["logDir"] = dir
+	}
+	if config := a.currentConfig(); config != nil {
+		env["scriptTasks"] = len(config.Tasks)
+		env["scriptFiles"] = countFiles(config.Tasks)
+	}
+	return env
+}
+
+func (a *App) diagnosticTasks() []map[string]any {
+	tasks := a.engine.GetRunningTasks()
+	result := make([]map[string]any, 0, len(tasks))
+	for _, task := range tasks {
+		m := task.ToMap()
+		m["url"] = backend.RedactURL(task.URL)
+		result = append(result, m)
+	}
+	return result
+}
+
+// diagnosticProbe 用当前连接设置探测脚本中的一个示例地址
+func (a *App) diagnosticProbe(ctx context.Context) map[string]any {
+	target := a.sampleURL()
+	if target == "" {
+		return map[string]any{"skipped": "未加载脚本，没有可探测的地址"}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsProbeTimeout)
+	defer cancel()
+
+	return map[string]any{
+		"url":    backend.RedactURL(target),
+		"result": a.engine.TestConnection(ctx, target),
+	}
+}
+
+// addLogFiles 附上磁盘上的滚动日志文件，写入时已去除签名
+func addLogFiles(zw *zip.Writer) error {
+	dir, err := backend.AppLogDir()
+	if err != nil {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.log*"))
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		w, err := zw.Create("logs/" + filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"isaac-downloader/backend"
+)
+
+func TestDiagnosticsBundleIsRedacted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	app := NewApp()
+	app.settings.Proxy = backend.ProxyConfig{Mode: backend.ProxyManual, URL: "http://proxy.local:8080", Username: "proxy-user", Password: "proxy-secret"}
+	app.settings.Hooks.OnFileComplete = "curl -H 'Authorization: Bearer hook-secret' https://example.com"
+	app.settings.API = APISettings{Enabled: true, Port: defaultAPIPort, Token: "api-secret"}
+	app.settings.Webhook = backend.WebhookConfig{URL: "https://hooks.slack.com/services/T000/B000/webhook-secret", Secret: "hmac-secret"}
+	app.engine.StartDownload(&backend.DownloadTask{
+		URL:       "http://127.0.0.1:1/a.bin?X-Amz-Signature=url-secret",
+		LocalPath: filepath.Join(t.TempDir(), "a.bin"),
+	})
+	app.engine.PauseAll()
+	app.logs.Add(backend.LogEntry{Level: "error", Message: "下载失败"})
+	history, err := backend.OpenHistoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Append(backend.HistoryRecord{TaskName: "journal-task", Host: "cdn.example.com", Outcome: "failed"})
+	app.history = history
+
+	path := filepath.Join(t.TempDir(), "diag.zip")
+	if err := app.writeDiagnostics(context.Background(), path); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("打开诊断包失败: %v", err)
+	}
+	defer reader.Close()
+
+	names := make(map[string]bool)
+	var all strings.Builder
+	for _, f := range reader.File {
+		names[f.Name] = true
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		all.Write(content)
+	}
+
+	for _, want := range []string{"environment.json", "settings.json", "tasks.json", "connectivity.json", "journal.json", "logs/recent.log"} {
+		if !names[want] {
+			t.Errorf("诊断包缺少 %s", want)
+		}
+	}
+	for _, secret := range []string{"proxy-secret", "api-secret", "webhook-secret", "hmac-secret", "url-secret", "proxy-user", "hook-secret"} {
+		if strings.Contains(all.String(), secret) {
+			t.Errorf("诊断包中不应出现 %s", secret)
+		}
+	}
+	if !strings.Contains(all.String(), "下载失败") || !strings.Contains(all.String(), appVersion) || !strings.Contains(all.String(), "journal-task") {
+		t.Errorf("诊断包应包含日志、下载记录和版本信息")
+	}
+}
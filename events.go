@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"isaac-downloader/backend"
+)
+
+// wailsSink 将事件转发给前端，事件名见 backend.Event.Name
+type wailsSink struct {
+	ctx context.Context
+}
+
+func (s wailsSink) Emit(ev backend.Event) {
+	if coalesced(ev) {
+		return
+	}
+	runtime.EventsEmit(s.ctx, ev.Name(), ev.Payload())
+}
+
+// coalesced 逐个任务的进度事件已由 ProgressAggregator 合并为 progressBatch，不再单独推送
+func coalesced(ev backend.Event) bool {
+	return ev.Type == backend.EventProgress
+}
+
+// emit 发出不属于具体下载任务的应用层事件
+func (a *App) emit(typ backend.EventType, data any) {
+	a.bus.Emit(backend.Event{Type: typ, Data: data})
+}
+
+// handleEvent 文件结束时更新批次统计、记录历史并触发钩子
+func (a *App) handleEvent(ev backend.Event) {
+	switch ev.Type {
+	case backend.EventCompleted, backend.EventFailed:
+		a.onFileFinished(ev.Task, ev.Err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"isaac-downloader/backend"
+)
+
+// openHistory 打开下载历史，失败时只记录日志，不影响下载
+func (a *App) openHistory() {
+	store, err := backend.OpenHistoryStore("")
+	if err != nil {
+		a.emitLog("error", fmt.Sprintf("打开下载历史失败: %v", err))
+		return
+	}
+	a.history = store
+}
+
+// GetHistory 按条件查询下载历史，最新的在前
+func (a *App) GetHistory(query backend.HistoryQuery) ([]backend.HistoryRecord, error) {
+	if a.history == nil {
+		return []backend.HistoryRecord{}, nil
+	}
+	return a.history.Query(query)
+}
+
+// ExportHistoryCSV 将符合条件的下载历史导出为 CSV，返回保存路径，用户取消时返回空字符串
+func (a *App) ExportHistoryCSV(query backend.HistoryQuery) (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出下载历史",
+		DefaultFilename: fmt.Sprintf("isaac-downloader-history-%s.csv", time.Now().Format("20060102-150405")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "CSV 文件 (*.csv)", Pattern: "*.csv"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := a.writeHistoryCSV(path, query); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (a *App) writeHistoryCSV(path string, query backend.HistoryQuery) error {
+	records, err := a.GetHistory(query)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer file.Close()
+
+	// 写入 BOM，Excel 打开时才能正确识别中文
+	if _, err := file.WriteString("\uFEFF"); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	if err := backend.WriteHistoryCSV(file, records); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return file.Close()
+}
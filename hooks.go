@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+// hookQueueSize 待执行钩子的缓冲数量，超出时丢弃并记录日志，避免阻塞下载
+const hookQueueSize = 256
+
+// webhookQueueSize 待推送通知的缓冲数量，超出时丢弃并记录日志
+const webhookQueueSize = 256
+
+// finishQueueSize 待计算哈希和写入历史的文件数量，超出时丢弃并记录日志，避免阻塞下载
+const finishQueueSize = 1024
+
+type hookJob struct {
+	event   string
+	command string
+	env     map[string]string
+}
+
+// startHookWorker 启动执行钩子和记录历史的后台协程
+// 文件结束后先在 finished 协程中计算哈希、写入历史，再按顺序提交钩子，
+// 钩子逐个执行，保证任务钩子在其最后一个文件钩子之后运行
+func (a *App) startHookWorker() {
+	a.hooks = make(chan hookJob, hookQueueSize)
+	a.webhooks = make(chan backend.WebhookEvent, webhookQueueSize)
+	a.resetWebhookNotifier()
+	a.finished = make(chan func(), finishQueueSize)
+	a.catalogScans = make(chan string, 1)
+	go func() {
+		for job := range a.hooks {
+			a.runHook(job)
+		}
+	}()
+	go func() {
+		for event := range a.webhooks {
+			a.sendWebhook(event)
+		}
+	}()
+	go func() {
+		for job := range a.finished {
+			job()
+		}
+	}()
+	go func() {
+		for root := range a.catalogScans {
+			a.scanCatalog(root)
+		}
+	}()
+}
+
+// onFileFinished 文件结束（成功或失败）后更新批次统计，记录下载历史，并提交对应的钩子和 Webhook 通知
+func (a *App) onFileFinished(task *backend.DownloadTask, err error) {
+	ok := err == nil
+	// 多个下载协程可能同时结束，加锁保证 batch.completed 在最后一个 task.completed 之后提交
+	a.finishMu.Lock()
+	defer a.finishMu.Unlock()
+	var taskDone *backend.TaskSummary
+	var batchDone *backend.BatchSummary
+	if batch := a.batch.Load(); batch != nil {
+		taskDone, batchDone = batch.Finish(task, ok)
+	}
+
+	// 耗时在结束时立即计算，哈希留到后台协程
+	record := backend.NewHistoryRecord(task, "", err)
+	settings := a.currentSettings()
+	hooks := settings.Hooks
+	hashFiles := settings.HashFiles
+	downloadPath := settings.DownloadPath
+	a.enqueueFinished(record.Path, func() {
+		var hash string
+		if ok && hashFiles {
+			// 开启解压后删除压缩包时文件已不存在，哈希留空
+			hash, _ = backend.HashFile(task.LocalPath)
+		}
+		if a.history != nil {
+			record.SHA256 = hash
+			if err := a.history.Append(record); err != nil {
+				a.emitLog("error", err.Error())
+			}
+		}
+		if ok && hooks.OnFileComplete != "" {
+			a.enqueueHook(backend.HookFileComplete, hooks.OnFileComplete, backend.FileHookEnv(task, hash))
+		}
+		if taskDone != nil && hooks.OnTaskComplete != "" {
+			a.enqueueHook(backend.HookTaskComplete, hooks.OnTaskComplete, backend.TaskHookEnv(taskDone, downloadPath))
+		}
+		if batchDone != nil && hooks.OnBatchComplete != "" {
+			a.enqueueHook(backend.HookBatchComplete, hooks.OnBatchComplete, backend.BatchHookEnv(batchDone, downloadPath))
+		}
+		// 批次结束后在后台把新下载的抓取目录加入索引
+		if batchDone != nil {
+			a.requestCatalogScan(downloadPath)
+		}
+	})
+
+	if settings.Webhook.Enabled() {
+		if taskDone != nil {
+			a.enqueueWebhook(backend.TaskWebhookEvent(taskDone))
+		}
+		if batchDone != nil {
+			a.enqueueWebhook(backend.BatchWebhookEvent(batchDone))
+		}
+	}
+}
+
+// enqueueWebhook 提交通知，队列已满或后台协程未启动时丢弃并记录日志，不阻塞下载
+func (a *App) enqueueWebhook(event backend.WebhookEvent) {
+	if a.webhooks == nil {
+		return
+	}
+	select {
+	case a.webhooks <- event:
+	default:
+		a.emitLog("error", fmt.Sprintf("Webhook %s 未推送：待推送通知过多", event.Event))
+	}
+}
+
+// enqueueFinished 提交文件结束后的后台处理，队列已满或后台协程未启动时丢弃并记录日志，不阻塞下载
+func (a *App) enqueueFinished(path string, job func()) {
+	if a.finished == nil {
+		return
+	}
+	select {
+	case a.finished <- job:
+	default:
+		a.emitLog("error", fmt.Sprintf("%s 的历史记录和钩子未处理：待处理文件过多", path))
+	}
+}
+
+// resetWebhookNotifier 按当前设置重建通知发送器，并关闭旧发送器的空闲连接
+func (a *App) resetWebhookNotifier() {
+	settings := a.currentSettings()
+	notifier := backend.NewWebhookNotifier(settings.Webhook, settings.engineOptions())
+	if old := a.notifier.Swap(notifier); old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+// sendWebhook 推送通知，重试耗尽后记录日志
+func (a *App) sendWebhook(event backend.WebhookEvent) {
+	notifier := a.notifier.Load()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Send(context.Background(), event); err != nil {
+		a.emitLog("error", fmt.Sprintf("Webhook %s 推送失败: %v", event.Event, err))
+	}
+}
+
+func (a *App) enqueueHook(event, command string, env map[string]string) {
+	if a.hooks == nil {
+		return
+	}
+	select {
+	case a.hooks <- hookJob{event: event, command: command, env: env}:
+	default:
+		a.emitLog("error", fmt.Sprintf("钩子 %s 未执行：待执行钩子过多", event))
+	}
+}
+
+// runHook 执行单个钩子，并将结果和输出追加到日志
+func (a *App) runHook(job hookJob) {
+	result, err := backend.RunHook(context.Background(), job.command, job.env, a.currentSettings().Hooks.Timeout())
+	if err != nil {
+		a.emitLog("error", fmt.Sprintf("钩子 %s 失败: %v", job.event, err))
+	} else {
+		a.emitLog("info", fmt.Sprintf("钩子 %s 完成，耗时 %s", job.event, result.Duration.Round(time.Millisecond)))
+	}
+	if result == nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(result.Output, "\r\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			a.emitLog("info", fmt.Sprintf("[%s] %s", job.event, line))
+		}
+	}
+}
+
+// emitLog 向前端日志面板追加一行，同时写入日志文件
+func (a *App) emitLog(level, message string) {
+	backend.Logger().Log(context.Background(), logLevel(level), message)
+	a.emit(backend.EventLog, map[string]any{
+		"level":   level,
+		"message": message,
+	})
+}
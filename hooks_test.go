@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+// TestWebhooksDeliveredInOrder 多个文件同时结束时，batch.completed 仍在所有 task 通知之后送达
+func TestWebhooksDeliveredInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event backend.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		// 先到的请求处理得更慢，如果并发发送，顺序会被打乱
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		time.Sleep(time.Duration(5-n) * 10 * time.Millisecond)
+		mu.Lock()
+		events = append(events, event.Event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	app := NewApp()
+	app.settings.Webhook = backend.WebhookConfig{URL: server.URL}
+	app.startHookWorker()
+
+	batch := backend.NewBatchTracker()
+	var tasks []*backend.DownloadTask
+	for i := 0; i < 4; i++ {
+		url := server.URL + "/f" + strconv.Itoa(i)
+		batch.Add(int64(i), "task", url)
+		tasks = append(tasks, &backend.DownloadTask{TaskId: int64(i), URL: url})
+	}
+	app.batch.Store(batch)
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task *backend.DownloadTask) {
+			defer wg.Done()
+			app.onFileFinished(task, nil)
+		}(task)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n == 5 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 5 || events[4] != backend.WebhookBatchCompleted {
+		t.Errorf("batch.completed 应最后送达，实际: %v", events)
+	}
+}
+
+// TestEnqueueFinishedDoesNotBlock 后台队列已满时丢弃任务，不阻塞下载协程
+func TestEnqueueFinishedDoesNotBlock(t *testing.T) {
+	app := NewApp()
+	app.finished = make(chan func(), 1)
+	app.finished <- func() {}
+
+	done := make(chan struct{})
+	go func() {
+		app.enqueueFinished("a.bin", func() {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("队列已满时 enqueueFinished 不应阻塞")
+	}
+	if len(app.finished) != 1 {
+		t.Errorf("队列已满时应丢弃新任务，队列长度 %d", len(app.finished))
+	}
+}
+
+// TestFileHashOptIn 只有开启 HashFiles 时才重新读取文件计算哈希
+func TestFileHashOptIn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "a.bin")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const helloHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	for _, hashFiles := range []bool{false, true} {
+		app := NewApp()
+		app.settings.HashFiles = hashFiles
+		history, err := backend.OpenHistoryStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.history = history
+		app.startHookWorker()
+
+		app.onFileFinished(&backend.DownloadTask{TaskId: 1, LocalPath: path, Status: backend.StatusCompleted}, nil)
+		var records []backend.HistoryRecord
+		deadline := time.Now().Add(5 * time.Second)
+		for len(records) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			records, _ = history.Query(backend.HistoryQuery{})
+		}
+		if len(records) != 1 {
+			t.Fatalf("HashFiles=%v: 应写入一条历史记录，实际 %d", hashFiles, len(records))
+		}
+		want := ""
+		if hashFiles {
+			want = helloHash
+		}
+		if records[0].SHA256 != want {
+			t.Errorf("HashFiles=%v: 哈希 = %q, 期望 %q", hashFiles, records[0].SHA256, want)
+		}
+	}
+}
+
+// TestWebhookNotifierReusesConnections 连续的通知复用同一个发送器和连接，不会每次新建 Transport
+func TestWebhookNotifierReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	remotes := map[string]bool{}
+	received := make(chan struct{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		received <- struct{}{}
+	}))
+	defer server.Close()
+
+	app := NewApp()
+	app.settings.Webhook = backend.WebhookConfig{URL: server.URL}
+	app.startHookWorker()
+	notifier := app.notifier.Load()
+
+	for i := 0; i < 2; i++ {
+		app.enqueueWebhook(backend.WebhookEvent{Event: backend.WebhookTaskCompleted})
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("未收到通知")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(remotes) != 1 {
+		t.Errorf("两次通知应复用同一连接，实际来自 %d 个连接", len(remotes))
+	}
+	if app.notifier.Load() != notifier {
+		t.Errorf("设置未变化时不应重建发送器")
+	}
+}
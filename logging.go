@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"isaac-downloader/backend"
+)
+
+// initLogging 将全局日志同时写入内存环形缓冲（供日志面板查询）和
+// 用户配置目录下按大小滚动的 JSON 日志文件；日志文件打开失败时只保留内存日志
+func (a *App) initLogging() {
+	handlers := []slog.Handler{backend.NewLogRingHandler(a.logs, slog.LevelInfo)}
+
+	path, err := backend.AppLogPath()
+	if err == nil {
+		var file *backend.RotatingFile
+		if file, err = backend.OpenRotatingFile(path, backend.DefaultLogMaxSize, backend.DefaultLogMaxBackups); err == nil {
+			a.logFile = file
+			handlers = append(handlers, backend.NewJSONHandler(file, slog.LevelInfo))
+		}
+	}
+	backend.SetLogger(slog.New(backend.NewTeeHandler(handlers...)))
+	if err != nil {
+		backend.Logger().Warn("无法写入日志文件", "error", err)
+		return
+	}
+	backend.Logger().Info("应用启动", "log_file", path)
+}
+
+// GetLogHistory 返回内存中保留的最近日志，taskId、level 为空表示不过滤，limit 为 0 表示全部
+func (a *App) GetLogHistory(taskId, level string, limit int) []backend.LogEntry {
+	return a.logs.Entries(backend.LogFilter{TaskId: taskId, MinLevel: level, Limit: limit})
+}
+
+// ExportLogs 将符合条件的日志导出为文本文件，返回保存路径；用户取消时返回空字符串
+func (a *App) ExportLogs(taskId, level string) (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出日志",
+		DefaultFilename: fmt.Sprintf("isaac-downloader-%s.log", time.Now().Format("20060102-150405")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "日志文件 (*.log;*.txt)", Pattern: "*.log;*.txt"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := a.writeLogExport(path, taskId, level); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (a *App) writeLogExport(path, taskId, level string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer file.Close()
+
+	entries := a.GetLogHistory(taskId, level, 0)
+	if err := backend.WriteLogText(file, entries); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return file.Close()
+}
+
+// OnShutdown 关闭控制接口并刷新日志文件
+func (a *App) OnShutdown(ctx context.Context) {
+	a.stopAPI()
+	a.stopMetrics()
+	backend.Logger().Info("应用退出")
+	if a.logFile != nil {
+		a.logFile.Close()
+	}
+}
+
+// logLevel 将前端日志级别映射为 slog 级别
+func logLevel(level string) slog.Level {
+	switch level {
+	case "error":
+		return slog.LevelError
+	case "warn":
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,6 +13,10 @@ import (
 var assets embed.FS
 
 func main() {
+	if code, handled := runCLI(os.Args[1:], os.Stdout, os.Stderr); handled {
+		os.Exit(code)
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -27,6 +32,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 245, G: 245, B: 247, A: 1},
 		OnStartup:        app.OnStartup,
+		OnShutdown:       app.OnShutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultMetricsAddr Prometheus 抓取地址，默认只监听本机
+const defaultMetricsAddr = "127.0.0.1:9464"
+
+// MetricsSettings 可选的 /metrics 接口，供无界面部署的节点被 Prometheus 抓取
+// 需要跨主机抓取时可将 Addr 改为 0.0.0.0:端口
+type MetricsSettings struct {
+	Enabled bool   `json:"enabled"`
+	Addr    string `json:"addr"`
+}
+
+func (m MetricsSettings) Validate() error {
+	if _, _, err := net.SplitHostPort(m.Addr); err != nil {
+		return fmt.Errorf("指标监听地址无效: %s", m.Addr)
+	}
+	return nil
+}
+
+// startMetrics 按设置启动 /metrics 监听，未启用时不做任何事
+func (a *App) startMetrics() error {
+	cfg := a.currentSettings().Metrics
+	if !cfg.Enabled {
+		return nil
+	}
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("指标接口监听失败: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		// 引擎可能在启动时被重建，每次请求时取当前引擎
+		a.engine.MetricsHandler().ServeHTTP(w, r)
+	})
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.metricsServer = server
+	go server.Serve(listener)
+	return nil
+}
+
+func (a *App) stopMetrics() {
+	if a.metricsServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	a.metricsServer.Shutdown(ctx)
+	a.metricsServer = nil
+}
+
+func (a *App) restartMetrics() {
+	a.stopMetrics()
+	if err := a.startMetrics(); err != nil {
+		a.emitLog("error", err.Error())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"isaac-downloader/backend"
+)
+
+// DryRun 预演开始下载时对每个文件的处理方式，不会修改磁盘
+// probe 为 true 时请求每个文件的远程大小，文件多时需要一些时间
+func (a *App) DryRun(probe bool) (*backend.DownloadPlan, error) {
+	config := a.currentConfig()
+	if config == nil {
+		return nil, fmt.Errorf("未加载配置")
+	}
+	settings := a.currentSettings()
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	plan := a.engine.BuildPlan(ctx, settings.DownloadPath, config.Tasks, backend.PlanOptions{
+		Probe:    probe,
+		Selected: a.selection.Selected,
+		Policy:   settings.ConflictPolicy,
+		Completed: func(url string) bool {
+			task := a.engine.GetTask(url)
+			return task != nil && task.GetStatus() == backend.StatusCompleted
+		},
+	})
+	a.lastPlan.Store(plan)
+
+	s := plan.Summary
+	backend.Logger().Info("预演下载", "files", s.Files, "download", s.Download, "resume", s.Resume,
+		"skip", s.Skip, "overwrite", s.Overwrite, "conflicts", s.Conflicts, "duplicates", s.Duplicates)
+	return plan, nil
+}
+
+// ExportPlan 将最近一次预演结果导出为 JSON，返回保存路径，用户取消时返回空字符串
+func (a *App) ExportPlan() (string, error) {
+	plan := a.lastPlan.Load()
+	if plan == nil {
+		return "", fmt.Errorf("请先预演下载")
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出下载计划",
+		DefaultFilename: fmt.Sprintf("isaac-downloader-plan-%s.json", plan.CreatedAt.Format("20060102-150405")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "JSON 文件 (*.json)", Pattern: "*.json"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := writePlanJSON(path, plan); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func writePlanJSON(path string, plan *backend.DownloadPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return nil
+}
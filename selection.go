@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"isaac-downloader/backend"
+)
+
+// TaskFileDisplay 任务中的单个文件及其勾选状态
+type TaskFileDisplay struct {
+	Path     string `json:"path"`
+	Checked  bool   `json:"checked"`  // 复选框状态
+	Selected bool   `json:"selected"` // 结合任务勾选和规则后是否会下载
+}
+
+// openSelection 读取选择性下载设置，失败时只记录日志并下载全部文件
+func (a *App) openSelection() {
+	store, err := backend.OpenSelectionStore("")
+	if err != nil {
+		a.emitLog("error", fmt.Sprintf("读取选择设置失败，将下载全部文件: %v", err))
+		return
+	}
+	a.selection = store
+}
+
+// useScriptSelection 切换到脚本保存的选择设置，有文件因此不会下载时记录提示
+func (a *App) useScriptSelection(scriptPath string, tasks []backend.TaskInfo) {
+	if a.selection == nil {
+		return
+	}
+	if err := a.selection.Use(scriptPath); err != nil {
+		a.emitLog("warn", err.Error())
+	}
+	skipped := 0
+	for _, task := range tasks {
+		skipped += len(task.Files) - a.selectedFiles(task)
+	}
+	if skipped > 0 {
+		a.emitLog("warn", fmt.Sprintf("已恢复该脚本保存的选择设置，%d 个文件不会下载", skipped))
+	}
+}
+
+func (a *App) requireSelection() (*backend.SelectionStore, error) {
+	if a.selection == nil {
+		return nil, fmt.Errorf("选择设置不可用")
+	}
+	return a.selection, nil
+}
+
+// GetSelection 返回当前的包含、排除规则和取消勾选的任务、文件
+func (a *App) GetSelection() backend.Selection {
+	if a.selection == nil {
+		return backend.Selection{Include: []backend.SelectionRule{}, Exclude: []backend.SelectionRule{}}
+	}
+	return a.selection.Selection()
+}
+
+// SetSelectionRules 替换包含和排除规则，规则无效时返回错误且不做修改
+func (a *App) SetSelectionRules(include, exclude []backend.SelectionRule) error {
+	store, err := a.requireSelection()
+	if err != nil {
+		return err
+	}
+	return store.SetRules(include, exclude)
+}
+
+// SetTaskSelected 勾选或取消勾选整个任务
+func (a *App) SetTaskSelected(taskId string, selected bool) error {
+	store, err := a.requireSelection()
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(taskId, 10, 64)
+	if err != nil {
+		return fmt.Errorf("任务 ID 无效: %s", taskId)
+	}
+	return store.SetTaskSelected(id, selected)
+}
+
+// SetFileSelected 勾选或取消勾选任务中的单个文件，filePath 为脚本中的相对路径
+func (a *App) SetFileSelected(taskId, filePath string, selected bool) error {
+	store, err := a.requireSelection()
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(taskId, 10, 64)
+	if err != nil {
+		return fmt.Errorf("任务 ID 无效: %s", taskId)
+	}
+	return store.SetFileSelected(id, filePath, selected)
+}
+
+// ResetSelection 清除所有规则和勾选状态，恢复为下载全部文件
+func (a *App) ResetSelection() error {
+	store, err := a.requireSelection()
+	if err != nil {
+		return err
+	}
+	return store.Reset()
+}
+
+// GetTaskFiles 返回任务中的文件及其勾选状态
+func (a *App) GetTaskFiles(taskId string) ([]TaskFileDisplay, error) {
+	config := a.currentConfig()
+	if config == nil {
+		return nil, fmt.Errorf("未加载配置")
+	}
+	excluded := make(map[string]bool)
+	if ts := a.GetSelection().Tasks[taskId]; ts != nil {
+		for _, p := range ts.ExcludedFiles {
+			excluded[p] = true
+		}
+	}
+	for _, task := range config.Tasks {
+		if strconv.FormatInt(task.TaskId, 10) != taskId {
+			continue
+		}
+		files := make([]TaskFileDisplay, len(task.Files))
+		for i, file := range task.Files {
+			files[i] = TaskFileDisplay{
+				Path:     file.Path,
+				Checked:  !excluded[file.Path],
+				Selected: a.selection.Selected(task, file),
+			}
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("任务不存在: %s", taskId)
+}
+
+// selectedFiles 返回任务中会被下载的文件数
+func (a *App) selectedFiles(task backend.TaskInfo) int {
+	n := 0
+	for _, file := range task.Files {
+		if a.selection.Selected(task, file) {
+			n++
+		}
+	}
+	return n
+}
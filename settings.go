@@ -0,0 +1,553 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"isaac-downloader/backend"
+)
+
+const (
+	settingsFileName = "settings.json"
+
+	// 代理密码加密保存在配置目录，不写入 settings.json
+	proxyPasswordSecret = "proxy-password"
+
+	defaultConcurrent   = 3
+	defaultDownloadPath = "./downloads"
+
+	minConcurrent = 1
+	maxConcurrent = 16
+
+	maxTimeoutSec   = 3600
+	maxBufferSizeKB = 4096
+	maxRetries      = 20
+
+	minProgressIntervalMs = 100
+	maxProgressIntervalMs = 10000
+)
+
+type Settings struct {
+	Concurrent   int    `json:"concurrent"`
+	DownloadPath string `json:"downloadPath"` // 前端使用 downloadPath (小写)
+	PlatformURL  string `json:"platformUrl"`  // 平台地址，为空时不启用登录
+
+	ConnectTimeoutSec      int    `json:"connectTimeoutSec"`
+	TLSHandshakeTimeoutSec int    `json:"tlsHandshakeTimeoutSec"`
+	ReadTimeoutSec         int    `json:"readTimeoutSec"`
+	UserAgent              string `json:"userAgent"`
+	BufferSizeKB           int    `json:"bufferSizeKb"`
+	MaxRetries             int    `json:"maxRetries"`
+	MaxConnsPerHost        int    `json:"maxConnsPerHost"` // 0 表示不限制
+	StallTimeoutSec        int    `json:"stallTimeoutSec"` // 无数据多久判定连接卡死
+
+	Proxy backend.ProxyConfig `json:"proxy"`
+	TLS   backend.TLSConfig   `json:"tls"`
+
+	// 只在提交设置时使用：为 true 时清除已保存的代理密码，本身不写入设置文件
+	ClearProxyPassword bool `json:"clearProxyPassword,omitempty"`
+
+	ValidateZip               bool `json:"validateZip"`
+	RedownloadCorrupt         bool `json:"redownloadCorrupt"`
+	AutoExtract               bool `json:"autoExtract"`
+	DeleteArchiveAfterExtract bool `json:"deleteArchiveAfterExtract"`
+
+	ProgressIntervalMs int `json:"progressIntervalMs"` // 合并推送进度的间隔
+
+	ConflictPolicy backend.ConflictPolicy `json:"conflictPolicy"` // 目标路径已有文件时的处理策略
+
+	// 文件完成后重新读取并计算 SHA-256，写入历史记录和钩子环境变量，大文件较慢，默认关闭
+	HashFiles bool `json:"hashFiles"`
+
+	Hooks   backend.HookConfig    `json:"hooks"`   // 下载完成后执行的命令
+	Webhook backend.WebhookConfig `json:"webhook"` // 任务和整批结束时推送通知
+	API     APISettings           `json:"api"`     // 本地 REST + SSE 控制接口
+	Metrics MetricsSettings       `json:"metrics"` // Prometheus 指标接口
+}
+
+func defaultSettings() *Settings {
+	def := backend.DefaultEngineOptions()
+	s := &Settings{
+		Concurrent:             defaultConcurrent,
+		DownloadPath:           defaultDownloadPath,
+		ConnectTimeoutSec:      int(def.ConnectTimeout / time.Second),
+		TLSHandshakeTimeoutSec: int(def.TLSHandshakeTimeout / time.Second),
+		ReadTimeoutSec:         int(def.ReadTimeout / time.Second),
+		UserAgent:              def.UserAgent,
+		BufferSizeKB:           def.BufferSize / 1024,
+		MaxRetries:             def.MaxRetries,
+		StallTimeoutSec:        int(def.StallTimeout / time.Second),
+		ValidateZip:            def.ValidateZip,
+		Webhook:                backend.WebhookConfig{MaxRetries: backend.DefaultWebhookRetries},
+	}
+	s.normalize()
+	return s
+}
+
+// engineOptions 转换为下载引擎参数
+func (s *Settings) engineOptions() backend.EngineOptions {
+	return backend.EngineOptions{
+		MaxConcurrent:       s.Concurrent,
+		ConnectTimeout:      time.Duration(s.ConnectTimeoutSec) * time.Second,
+		TLSHandshakeTimeout: time.Duration(s.TLSHandshakeTimeoutSec) * time.Second,
+		ReadTimeout:         time.Duration(s.ReadTimeoutSec) * time.Second,
+		UserAgent:           s.UserAgent,
+		BufferSize:          s.BufferSizeKB * 1024,
+		MaxRetries:          s.MaxRetries,
+		MaxConnsPerHost:     s.MaxConnsPerHost,
+		StallTimeout:        time.Duration(s.StallTimeoutSec) * time.Second,
+		Proxy:               s.Proxy,
+		TLS:                 s.TLS,
+
+		ValidateZip:               s.ValidateZip,
+		RedownloadCorrupt:         s.RedownloadCorrupt,
+		AutoExtract:               s.AutoExtract,
+		DeleteArchiveAfterExtract: s.DeleteArchiveAfterExtract,
+
+		ConflictPolicy: s.ConflictPolicy,
+	}
+}
+
+func (s *Settings) progressInterval() time.Duration {
+	return time.Duration(s.ProgressIntervalMs) * time.Millisecond
+}
+
+// normalize 补全缺失字段并将相对路径解析为绝对路径
+func (s *Settings) normalize() {
+	def := backend.DefaultEngineOptions()
+	if s.Concurrent == 0 {
+		s.Concurrent = defaultConcurrent
+	}
+	if s.DownloadPath == "" {
+		s.DownloadPath = defaultDownloadPath
+	}
+	if s.ConnectTimeoutSec == 0 {
+		s.ConnectTimeoutSec = int(def.ConnectTimeout / time.Second)
+	}
+	if s.TLSHandshakeTimeoutSec == 0 {
+		s.TLSHandshakeTimeoutSec = int(def.TLSHandshakeTimeout / time.Second)
+	}
+	if s.ReadTimeoutSec == 0 {
+		s.ReadTimeoutSec = int(def.ReadTimeout / time.Second)
+	}
+	if s.StallTimeoutSec == 0 {
+		s.StallTimeoutSec = int(def.StallTimeout / time.Second)
+	}
+	if s.UserAgent == "" {
+		s.UserAgent = def.UserAgent
+	}
+	if s.Proxy.Mode == "" {
+		s.Proxy.Mode = backend.ProxySystem
+	}
+	if s.BufferSizeKB == 0 {
+		s.BufferSizeKB = def.BufferSize / 1024
+	}
+	if s.ProgressIntervalMs == 0 {
+		s.ProgressIntervalMs = int(backend.DefaultProgressInterval / time.Millisecond)
+	}
+	if s.Metrics.Addr == "" {
+		s.Metrics.Addr = defaultMetricsAddr
+	}
+	if s.API.Port == 0 {
+		s.API.Port = defaultAPIPort
+	}
+	if s.Webhook.Format == "" {
+		s.Webhook.Format = backend.WebhookGeneric
+	}
+	if s.ConflictPolicy == "" {
+		s.ConflictPolicy = backend.ConflictResume
+	}
+	if s.Hooks.TimeoutSec == 0 {
+		s.Hooks.TimeoutSec = int(backend.DefaultHookTimeout / time.Second)
+	}
+	if !filepath.IsAbs(s.DownloadPath) {
+		abs, err := filepath.Abs(s.DownloadPath)
+		if err == nil {
+			s.DownloadPath = abs
+		}
+	}
+}
+
+// settingsCheck 校验一组相关字段，reset 将这组字段恢复为默认值
+type settingsCheck struct {
+	check func(s *Settings) error
+	reset func(s, def *Settings)
+}
+
+// timeoutCheck 校验以秒为单位的超时字段
+func timeoutCheck(name string, field func(s *Settings) *int) settingsCheck {
+	return settingsCheck{
+		check: func(s *Settings) error {
+			if sec := *field(s); sec < 1 || sec > maxTimeoutSec {
+				return fmt.Errorf("%s必须在 1 到 %d 秒之间", name, maxTimeoutSec)
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { *field(s) = *field(def) },
+	}
+}
+
+// settingsChecks 不包含下载目录检查，下载目录是否可用取决于运行环境而不是设置本身
+var settingsChecks = []settingsCheck{
+	{
+		check: func(s *Settings) error {
+			if s.Concurrent < minConcurrent || s.Concurrent > maxConcurrent {
+				return fmt.Errorf("并发数必须在 %d 到 %d 之间", minConcurrent, maxConcurrent)
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.Concurrent = def.Concurrent },
+	},
+	timeoutCheck("连接超时", func(s *Settings) *int { return &s.ConnectTimeoutSec }),
+	timeoutCheck("TLS 握手超时", func(s *Settings) *int { return &s.TLSHandshakeTimeoutSec }),
+	timeoutCheck("读取超时", func(s *Settings) *int { return &s.ReadTimeoutSec }),
+	timeoutCheck("卡死检测时间", func(s *Settings) *int { return &s.StallTimeoutSec }),
+	timeoutCheck("钩子超时", func(s *Settings) *int { return &s.Hooks.TimeoutSec }),
+	{
+		check: func(s *Settings) error {
+			if s.BufferSizeKB < 1 || s.BufferSizeKB > maxBufferSizeKB {
+				return fmt.Errorf("缓冲区大小必须在 1 到 %d KB 之间", maxBufferSizeKB)
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.BufferSizeKB = def.BufferSizeKB },
+	},
+	{
+		check: func(s *Settings) error {
+			if s.MaxRetries < 0 || s.MaxRetries > maxRetries {
+				return fmt.Errorf("重试次数必须在 0 到 %d 之间", maxRetries)
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.MaxRetries = def.MaxRetries },
+	},
+	{
+		check: func(s *Settings) error {
+			if s.ProgressIntervalMs < minProgressIntervalMs || s.ProgressIntervalMs > maxProgressIntervalMs {
+				return fmt.Errorf("进度推送间隔必须在 %d 到 %d 毫秒之间", minProgressIntervalMs, maxProgressIntervalMs)
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.ProgressIntervalMs = def.ProgressIntervalMs },
+	},
+	{
+		check: func(s *Settings) error {
+			if s.MaxConnsPerHost < 0 {
+				return fmt.Errorf("每主机连接数不能为负数")
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.MaxConnsPerHost = def.MaxConnsPerHost },
+	},
+	{
+		check: func(s *Settings) error { return s.ConflictPolicy.Validate() },
+		reset: func(s, def *Settings) { s.ConflictPolicy = def.ConflictPolicy },
+	},
+	{
+		check: func(s *Settings) error { return s.Proxy.Validate() },
+		reset: func(s, def *Settings) { s.Proxy = def.Proxy },
+	},
+	{
+		check: func(s *Settings) error { return s.TLS.Validate() },
+		reset: func(s, def *Settings) { s.TLS = def.TLS },
+	},
+	{
+		check: func(s *Settings) error { return s.Webhook.Validate() },
+		reset: func(s, def *Settings) { s.Webhook = def.Webhook },
+	},
+	{
+		check: func(s *Settings) error {
+			if s.API.Port < 1 || s.API.Port > 65535 {
+				return fmt.Errorf("控制接口端口必须在 1 到 65535 之间")
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.API.Port = def.API.Port },
+	},
+	{
+		check: func(s *Settings) error { return s.Metrics.Validate() },
+		reset: func(s, def *Settings) { s.Metrics = def.Metrics },
+	},
+	{
+		check: func(s *Settings) error {
+			if s.PlatformURL != "" {
+				u, err := url.Parse(s.PlatformURL)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return fmt.Errorf("平台地址无效: %s", s.PlatformURL)
+				}
+			}
+			return nil
+		},
+		reset: func(s, def *Settings) { s.PlatformURL = def.PlatformURL },
+	},
+}
+
+// Validate 校验并发数范围、下载目录可写以及平台地址格式
+// 下载目录不存在时会尝试创建
+func (s *Settings) Validate() error {
+	for _, c := range settingsChecks {
+		if err := c.check(s); err != nil {
+			return err
+		}
+	}
+	return checkWritableDir(s.DownloadPath)
+}
+
+// repair 将校验失败的字段恢复为默认值，其余字段保持不变，返回每个失败字段的错误
+// 下载目录不可用时保留原路径，目录可能只是暂时不可访问（例如移动硬盘未连接）
+func (s *Settings) repair() []error {
+	def := defaultSettings()
+	var errs []error
+	for _, c := range settingsChecks {
+		if err := c.check(s); err != nil {
+			c.reset(s, def)
+			errs = append(errs, err)
+		}
+	}
+	if err := checkWritableDir(s.DownloadPath); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("无法创建下载目录: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("下载目录不可用: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("下载路径不是目录: %s", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("下载目录不可写: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+func settingsFilePath() (string, error) {
+	dir, err := backend.AppConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, settingsFileName), nil
+}
+
+// loadSettingsFile 读取设置文件，文件不存在时返回默认设置
+// 个别字段类型不匹配时其余字段照常加载，同时返回错误
+func loadSettingsFile(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultSettings(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取设置失败: %w", err)
+	}
+
+	settings := defaultSettings()
+	var loadErr error
+	if err := json.Unmarshal(data, settings); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("设置文件格式错误: %w", err)
+		}
+		loadErr = fmt.Errorf("设置文件格式错误: %w", err)
+	}
+	settings.normalize()
+	settings.ClearProxyPassword = false
+
+	if settings.Proxy.Password != "" {
+		// 旧版本明文保存的密码，重新保存以迁移到加密文件；文件有错误时不改写，留给用户检查
+		if loadErr != nil {
+			return settings, loadErr
+		}
+		return settings, saveSettingsFile(path, settings)
+	}
+	// 无论其他字段是否有错误都读取密码，否则下次保存时会丢失已保存的密码
+	secrets := backend.NewTokenStore(filepath.Dir(path))
+	if settings.Proxy.Password, err = secrets.LoadSecret(proxyPasswordSecret); err != nil {
+		return settings, errors.Join(loadErr, fmt.Errorf("读取代理密码失败: %w", err))
+	}
+	return settings, loadErr
+}
+
+// saveSettingsFile 先写临时文件再重命名，避免写入中断导致设置文件损坏
+// 代理密码单独加密保存；密码为空时保留已保存的密码，只有 ClearProxyPassword 才会删除
+func saveSettingsFile(path string, settings *Settings) error {
+	secrets := backend.NewTokenStore(filepath.Dir(path))
+	var err error
+	switch {
+	case settings.ClearProxyPassword:
+		err = secrets.SaveSecret(proxyPasswordSecret, "")
+	case settings.Proxy.Password != "":
+		err = secrets.SaveSecret(proxyPasswordSecret, settings.Proxy.Password)
+	}
+	if err != nil {
+		return err
+	}
+	stored := *settings
+	stored.Proxy.Password = ""
+	stored.ClearProxyPassword = false
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("保存设置失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存设置失败: %w", err)
+	}
+	return nil
+}
+
+// loadPersistedSettings 启动时加载已保存的设置
+// 校验失败的字段恢复为默认值并记录日志；文件无法解析时先备份，避免下次保存时覆盖用户的设置
+func (a *App) loadPersistedSettings() {
+	path, err := settingsFilePath()
+	if err != nil {
+		a.emitLog("error", err.Error())
+		return
+	}
+	a.settingsPath = path
+
+	settings, err := loadSettingsFile(path)
+	if settings == nil {
+		a.emitLog("error", err.Error())
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			backup := path + ".bad"
+			if err := os.Rename(path, backup); err == nil {
+				a.emitLog("warn", fmt.Sprintf("已使用默认设置，原设置文件已备份为 %s", backup))
+			}
+		}
+		return
+	}
+	if err != nil {
+		a.emitLog("warn", err.Error())
+	}
+	for _, err := range settings.repair() {
+		a.emitLog("warn", fmt.Sprintf("设置项无效: %v", err))
+	}
+	a.setSettings(settings)
+}
+
+// GetSettings 返回当前设置，代理密码只写不读
+func (a *App) GetSettings() *Settings {
+	copied := *a.currentSettings()
+	copied.Proxy.Password = ""
+	return &copied
+}
+
+// SetSettings 保存前端提交的完整设置，未填写的并发数、路径和代理密码沿用当前值
+// 清除已保存的代理密码需设置 ClearProxyPassword
+func (a *App) SetSettings(settings *Settings) error {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+	return a.setSettingsLocked(settings)
+}
+
+// patchSettings 在当前设置的副本上执行 patch 后保存，读取和保存之间不会被其他修改打断
+func (a *App) patchSettings(patch func(next, current *Settings) error) error {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+	current := a.currentSettings()
+	next := *current
+	if err := patch(&next, current); err != nil {
+		return err
+	}
+	return a.setSettingsLocked(&next)
+}
+
+// setSettingsLocked 调用方需持有 updateMu
+func (a *App) setSettingsLocked(settings *Settings) error {
+	current := a.currentSettings()
+	next := *settings
+	if next.Concurrent <= 0 {
+		next.Concurrent = current.Concurrent
+	}
+	if next.ClearProxyPassword {
+		next.Proxy.Password = ""
+	} else if next.Proxy.Password == "" {
+		next.Proxy.Password = current.Proxy.Password
+	}
+	if next.DownloadPath == "" {
+		next.DownloadPath = current.DownloadPath
+	}
+	next.normalize()
+
+	return a.applySettings(&next)
+}
+
+// ResetSettings 恢复默认设置并保存，同时清除已保存的代理密码
+func (a *App) ResetSettings() (*Settings, error) {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+	def := defaultSettings()
+	def.ClearProxyPassword = true
+	if err := a.applySettings(def); err != nil {
+		return nil, err
+	}
+	return a.currentSettings(), nil
+}
+
+// applySettings 校验、保存并发布新设置，调用方需持有 updateMu
+func (a *App) applySettings(next *Settings) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	if a.settingsPath != "" {
+		if err := saveSettingsFile(a.settingsPath, next); err != nil {
+			return err
+		}
+	}
+	next.ClearProxyPassword = false
+
+	backend.Logger().Info("设置已更新")
+	current := a.currentSettings()
+	platformChanged := next.PlatformURL != current.PlatformURL
+	apiChanged := next.API != current.API
+	metricsChanged := next.Metrics != current.Metrics
+	a.setSettings(next)
+	if platformChanged {
+		a.initAuth()
+		a.engine.SetAuth(a.currentAuth())
+	}
+	a.engine.ApplyOptions(next.engineOptions())
+	a.progress.SetInterval(next.progressInterval())
+	if a.webhooks != nil {
+		a.resetWebhookNotifier()
+	}
+	if apiChanged {
+		a.ensureAPIToken()
+		// 设置可能由控制接口自身提交，异步重启以免在请求处理中关闭服务
+		go a.restartAPI()
+	}
+	if metricsChanged {
+		go a.restartMetrics()
+	}
+	return nil
+}
+
+// rebuildEngine 按当前设置重新创建下载引擎，仅在启动时调用
+func (a *App) rebuildEngine() {
+	a.engine = backend.NewDownloadEngine(a.currentSettings().engineOptions())
+	a.engine.SetAuth(a.currentAuth())
+	a.engine.SetConflictResolver(a.askConflict)
+	a.engine.Events().Subscribe(a.bus)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSettingsPersistence 验证设置保存后可以原样加载
+// 覆盖问题: 每次启动都丢失下载目录和并发数
+func TestSettingsPersistence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, settingsFileName)
+
+	// 文件不存在时返回默认设置
+	settings, err := loadSettingsFile(path)
+	if err != nil {
+		t.Fatalf("加载默认设置失败: %v", err)
+	}
+	if settings.Concurrent != defaultConcurrent {
+		t.Errorf("期望默认并发数 %d，实际: %d", defaultConcurrent, settings.Concurrent)
+	}
+
+	settings.Concurrent = 5
+	settings.DownloadPath = filepath.Join(dir, "data")
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatalf("保存设置失败: %v", err)
+	}
+
+	loaded, err := loadSettingsFile(path)
+	if err != nil {
+		t.Fatalf("加载设置失败: %v", err)
+	}
+	if loaded.Concurrent != 5 || loaded.DownloadPath != settings.DownloadPath {
+		t.Errorf("加载结果不一致: %+v", loaded)
+	}
+}
+
+// TestSettingsValidate 验证并发数范围和下载目录检查
+func TestSettingsValidate(t *testing.T) {
+	dir := t.TempDir()
+
+	withPath := func(path string) *Settings {
+		s := defaultSettings()
+		s.DownloadPath = path
+		return s
+	}
+
+	valid := withPath(filepath.Join(dir, "new"))
+	if err := valid.Validate(); err != nil {
+		t.Errorf("期望校验通过，实际: %v", err)
+	}
+	if _, err := os.Stat(valid.DownloadPath); err != nil {
+		t.Errorf("校验时应创建下载目录: %v", err)
+	}
+
+	for _, concurrent := range []int{0, maxConcurrent + 1} {
+		s := withPath(dir)
+		s.Concurrent = concurrent
+		if err := s.Validate(); err == nil {
+			t.Errorf("并发数 %d 应校验失败", concurrent)
+		}
+	}
+
+	file := filepath.Join(dir, "file.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+	if err := withPath(file).Validate(); err == nil {
+		t.Errorf("下载路径为文件时应校验失败")
+	}
+
+	badURL := withPath(dir)
+	badURL.PlatformURL = "ftp://example.com"
+	if err := badURL.Validate(); err == nil {
+		t.Errorf("非 http(s) 平台地址应校验失败")
+	}
+
+	badTimeout := withPath(dir)
+	badTimeout.ReadTimeoutSec = maxTimeoutSec + 1
+	if err := badTimeout.Validate(); err == nil {
+		t.Errorf("超出范围的超时应校验失败")
+	}
+
+	badPolicy := withPath(dir)
+	badPolicy.ConflictPolicy = "delete"
+	if err := badPolicy.Validate(); err == nil {
+		t.Errorf("不支持的文件冲突策略应校验失败")
+	}
+}
+
+// TestLoadPersistedSettingsKeepsValidFields 只有校验失败的字段恢复默认值
+// 覆盖问题: 下载目录暂时不可用或单个字段无效时整个设置文件被丢弃，下次保存时被默认值覆盖
+func TestLoadPersistedSettingsKeepsValidFields(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := settingsFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 下载目录的父路径是文件，目录无法创建
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	os.WriteFile(blocker, []byte("x"), 0644)
+	saved := defaultSettings()
+	saved.DownloadPath = filepath.Join(blocker, "data")
+	saved.Concurrent = maxConcurrent + 1
+	saved.UserAgent = "custom-agent"
+	saved.PlatformURL = "https://platform.example.com"
+	if err := saveSettingsFile(path, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp()
+	app.loadPersistedSettings()
+	got := app.settings
+	if got.Concurrent != defaultConcurrent {
+		t.Errorf("无效的并发数应恢复默认值，实际: %d", got.Concurrent)
+	}
+	if got.DownloadPath != saved.DownloadPath || got.UserAgent != "custom-agent" || got.PlatformURL != saved.PlatformURL {
+		t.Errorf("有效字段和暂时不可用的下载目录应保留: %+v", got)
+	}
+}
+
+// TestLoadPersistedSettingsBacksUpCorruptFile 设置文件无法解析时备份原文件
+func TestLoadPersistedSettingsBacksUpCorruptFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := settingsFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte(`{"concurrent": 5,`), 0600)
+
+	app := NewApp()
+	app.loadPersistedSettings()
+	if app.settings.Concurrent != defaultConcurrent {
+		t.Errorf("无法解析时应使用默认设置")
+	}
+	if data, err := os.ReadFile(path + ".bad"); err != nil || string(data) != `{"concurrent": 5,` {
+		t.Errorf("原设置文件应备份: %q, %v", data, err)
+	}
+}
+
+// TestProxyPasswordStoredEncrypted 代理密码不以明文写入 settings.json，也不通过 GetSettings 返回
+func TestProxyPasswordStoredEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, settingsFileName)
+
+	settings := defaultSettings()
+	settings.Proxy.Username = "u"
+	settings.Proxy.Password = "proxy-secret"
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatalf("保存设置失败: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "proxy-secret") {
+		t.Errorf("settings.json 不应包含明文代理密码")
+	}
+
+	loaded, err := loadSettingsFile(path)
+	if err != nil {
+		t.Fatalf("加载设置失败: %v", err)
+	}
+	if loaded.Proxy.Password != "proxy-secret" {
+		t.Errorf("加载后应恢复代理密码，实际: %q", loaded.Proxy.Password)
+	}
+
+	app := NewApp()
+	app.settings = loaded
+	if got := app.GetSettings(); got.Proxy.Password != "" || app.settings.Proxy.Password != "proxy-secret" {
+		t.Errorf("GetSettings 不应返回代理密码: %q", got.Proxy.Password)
+	}
+	next := app.GetSettings()
+	next.DownloadPath = dir
+	if err := app.SetSettings(next); err != nil {
+		t.Fatalf("保存设置失败: %v", err)
+	}
+	if app.settings.Proxy.Password != "proxy-secret" {
+		t.Errorf("未修改密码时应沿用当前密码")
+	}
+}
+
+// TestPlaintextProxyPasswordMigrated 旧版本明文保存的代理密码在加载时迁移
+func TestPlaintextProxyPasswordMigrated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, settingsFileName)
+	os.WriteFile(path, []byte(`{"proxy":{"mode":"manual","url":"http://proxy:8080","username":"u","password":"old-secret"}}`), 0600)
+
+	loaded, err := loadSettingsFile(path)
+	if err != nil {
+		t.Fatalf("加载设置失败: %v", err)
+	}
+	if loaded.Proxy.Password != "old-secret" {
+		t.Errorf("应读取旧版本的代理密码")
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "old-secret") {
+		t.Errorf("加载后应移除明文代理密码")
+	}
+}
+
+// TestTypeErrorKeepsProxyPassword 个别字段类型错误时仍读取代理密码，之后保存不会删除它
+func TestTypeErrorKeepsProxyPassword(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, settingsFileName)
+	settings := defaultSettings()
+	settings.Proxy.Password = "proxy-secret"
+	if err := saveSettingsFile(path, settings); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte(`{"concurrent":"5","proxy":{"username":"u"}}`), 0600)
+
+	loaded, err := loadSettingsFile(path)
+	if err == nil {
+		t.Fatal("类型错误时应返回错误")
+	}
+	if loaded.Proxy.Password != "proxy-secret" {
+		t.Errorf("类型错误时也应读取代理密码，实际: %q", loaded.Proxy.Password)
+	}
+
+	// 即使内存中的密码为空，保存也不应删除已保存的密码
+	loaded.Proxy.Password = ""
+	if err := saveSettingsFile(path, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := loadSettingsFile(path); reloaded.Proxy.Password != "proxy-secret" {
+		t.Errorf("保存后代理密码丢失: %q", reloaded.Proxy.Password)
+	}
+}
+
+// TestClearProxyPassword 空密码表示沿用，只有 ClearProxyPassword 才会清除已保存的密码
+func TestClearProxyPassword(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	app := NewApp()
+	app.loadPersistedSettings()
+	next := app.GetSettings()
+	next.DownloadPath = t.TempDir()
+	next.Proxy.Username = "u"
+	next.Proxy.Password = "proxy-secret"
+	if err := app.SetSettings(next); err != nil {
+		t.Fatal(err)
+	}
+
+	next = app.GetSettings()
+	next.Proxy.Username = "other"
+	if err := app.SetSettings(next); err != nil {
+		t.Fatal(err)
+	}
+	if app.settings.Proxy.Password != "proxy-secret" {
+		t.Errorf("未要求清除时应沿用当前密码")
+	}
+
+	next = app.GetSettings()
+	next.ClearProxyPassword = true
+	if err := app.SetSettings(next); err != nil {
+		t.Fatal(err)
+	}
+	if app.settings.Proxy.Password != "" || app.settings.ClearProxyPassword {
+		t.Errorf("应清除代理密码: %+v", app.settings.Proxy)
+	}
+	if loaded, err := loadSettingsFile(app.settingsPath); err != nil || loaded.Proxy.Password != "" {
+		t.Errorf("重新加载后不应有代理密码: %q, %v", loaded.Proxy.Password, err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"isaac-downloader/backend"
+)
+
+// VerifyDownloads 将下载目录与已加载的脚本比对，报告缺失、不完整、多余和不一致的文件
+// deep 为 true 时计算哈希并完整校验压缩包，大目录会比较慢
+func (a *App) VerifyDownloads(deep bool) (*backend.VerifyReport, error) {
+	config := a.currentConfig()
+	if config == nil {
+		return nil, fmt.Errorf("未加载配置")
+	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	report, err := backend.VerifyDirectory(ctx, a.currentSettings().DownloadPath, config.Tasks, backend.VerifyOptions{
+		Deep:     deep,
+		Selected: a.selection.Selected,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := report.Summary
+	backend.Logger().Info("校验下载目录", "ok", s.OK, "missing", s.Missing, "partial", s.Partial, "mismatch", s.Mismatch, "extra", s.Extra)
+	return report, nil
+}
+
+// DownloadMissing 校验下载目录后只下载缺失和不完整的文件，不完整的文件从已有大小续传
+// 无论设置中的冲突策略如何都按续传处理，不会另存或先删除已有文件，只有服务器不支持续传时才从头重写
+// 大小或哈希不一致的文件需要先删除，这里不会下载
+func (a *App) DownloadMissing() (int, error) {
+	report, err := a.VerifyDownloads(false)
+	if err != nil {
+		return 0, err
+	}
+	wanted := make(map[string]bool)
+	for _, path := range report.Paths(backend.VerifyMissing, backend.VerifyPartial) {
+		wanted[path] = true
+	}
+	if len(wanted) == 0 {
+		return 0, nil
+	}
+
+	a.engine.ResetGlobalCtx()
+	// 文件写回校验时的路径，补全后再次校验才能通过
+	started := a.startFiles(backend.DecisionResume, func(task backend.TaskInfo, file backend.FileInfo) bool {
+		if !wanted[filepath.ToSlash(filepath.Clean(filepath.FromSlash(file.Path)))] {
+			return false
+		}
+		// 正在下载的文件不重复提交；已完成但文件被删除的需要重新下载
+		existing := a.engine.GetTask(file.URL)
+		if existing == nil {
+			return true
+		}
+		status := existing.GetStatus()
+		return status != backend.StatusPending && status != backend.StatusDownloading && status != backend.StatusExtracting
+	})
+	backend.Logger().Info("补全缺失文件", "files", started)
+	return started, nil
+}